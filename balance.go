@@ -0,0 +1,139 @@
+package rope
+
+import "math"
+
+// rebalanceDepth is the default depth beyond which Append, Prepend and Insert rebalance the tree they return.
+const rebalanceDepth = 32
+
+// isBalanced reports whether a tree is balanced in the sense of Boehm, Atkinson and Plass: a tree of depth d must
+// contain at least fib(d+2) leaves. Length is measured in units of MinLeafSize, so that a tree of many small leaves
+// is unbalanced and rebalancing it coalesces them. Leaves are always balanced.
+func (o *Options) isBalanced(r Rope) bool {
+	d := r.Depth()
+	if d <= 1 {
+		return true
+	}
+	return d+2 < len(fibonacci) && fibonacci[d+2] <= r.Length()/o.MinLeafSize
+}
+
+// autoBalance rebalances a tree if it has grown deeper than RebalanceDepth.
+func (o *Options) autoBalance(r Rope) Rope {
+	if r.Depth() > o.RebalanceDepth {
+		return r.Balance()
+	}
+	return r
+}
+
+// rebalance rebuilds a tree using the algorithm of Boehm, Atkinson and Plass. Balanced subtrees are kept whole and
+// added in order to a forest of trees of increasing size, concatenating them as they fill each slot, while the
+// leaves of unbalanced subtrees which are shorter than MinLeafSize are coalesced.
+func (o *Options) rebalance(r Rope) Rope {
+	b := &balancer{
+		opts:   o,
+		forest: make([]Rope, len(fibonacci)-2),
+	}
+	b.add(r)
+	b.flush()
+	var result Rope
+	for _, t := range b.forest {
+		if t == nil {
+			continue
+		}
+		if result == nil {
+			result = t
+		} else {
+			result = o.newNode(t, result)
+		}
+	}
+	if result == nil {
+		return o.newLeaf("")
+	}
+	return result
+}
+
+// balancer holds the state of a rebalance.
+type balancer struct {
+	opts *Options
+	// forest[i] is nil or a balanced tree of length in [minLength(i), minLength(i+1)), holding runes which
+	// precede those of forest[i-1].
+	forest []Rope
+	// pending holds the text of small leaves waiting to be coalesced, and pendingLength its length in runes.
+	pending       []byte
+	pendingLength int
+}
+
+// add adds the subtrees of a tree to the forest, from left to right.
+func (b *balancer) add(r Rope) {
+	if count := r.childCount(); count > 0 && !b.opts.isBalanced(r) {
+		for i := 0; i < count; i++ {
+			b.add(r.child(i))
+		}
+		return
+	}
+	if r.Length() == 0 {
+		return
+	}
+	if r.childCount() == 0 && r.Length() < b.opts.MinLeafSize {
+		if b.pendingLength+r.Length() > b.opts.MaxLeafSize || !joinable(b.pending, r.String()) {
+			b.flush()
+		}
+		b.pending = append(b.pending, r.String()...)
+		b.pendingLength += r.Length()
+		return
+	}
+	b.flush()
+	b.insert(r)
+}
+
+// flush adds the coalesced small leaves to the forest.
+func (b *balancer) flush() {
+	if b.pendingLength == 0 {
+		return
+	}
+	b.insert(b.opts.newLeaf(string(b.pending)))
+	b.pending = b.pending[:0]
+	b.pendingLength = 0
+}
+
+// insert adds a balanced tree to the forest. The trees in the smaller slots are concatenated in front of it, and
+// the result is then concatenated with the trees in larger slots until it fits in an empty one.
+func (b *balancer) insert(r Rope) {
+	var i int
+	var prefix Rope
+	for ; r.Length() >= b.minLength(i+1); i++ {
+		if b.forest[i] != nil {
+			prefix = b.join(b.forest[i], prefix)
+			b.forest[i] = nil
+		}
+	}
+	r = b.join(prefix, r)
+	for ; ; i++ {
+		if b.forest[i] != nil {
+			r = b.join(b.forest[i], r)
+			b.forest[i] = nil
+		}
+		if i == len(b.forest)-1 || r.Length() < b.minLength(i+1) {
+			b.forest[i] = r
+			return
+		}
+	}
+}
+
+// minLength returns the minimum length of a tree in the i-th slot of the forest.
+func (b *balancer) minLength(i int) int {
+	if i+2 >= len(fibonacci) {
+		return math.MaxInt
+	}
+	return fibonacci[i+2] * b.opts.MinLeafSize
+}
+
+// join concatenates two trees, either of which may be nil.
+func (b *balancer) join(l, r Rope) Rope {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	return b.opts.newNode(l, r)
+}
@@ -0,0 +1,111 @@
+package rope
+
+import (
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxBalancedDepth returns a depth bound which is logarithmic in the number of runes in a tree.
+func maxBalancedDepth(length int) int {
+	return 2 * bits.Len(uint(length/defaultOptions.MinLeafSize+1))
+}
+
+func TestRope_AutoBalance(t *testing.T) {
+	const n = 100_000
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		edit func(r Rope) Rope
+	}{
+		{
+			name: "append",
+			edit: func(r Rope) Rope {
+				return r.Append(FromRune('a'))
+			},
+		},
+		{
+			name: "prepend",
+			edit: func(r Rope) Rope {
+				return r.Prepend(FromRune('a'))
+			},
+		},
+		{
+			name: "insert",
+			edit: func(r Rope) Rope {
+				return r.Insert(rng.Intn(r.Length()+1), FromRune('a'))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := FromString("")
+			for i := 0; i < n; i++ {
+				r = tt.edit(r)
+				require.LessOrEqual(t, r.Depth(), rebalanceDepth+1)
+			}
+			assert.Equal(t, n, r.Length())
+			assert.Equal(t, strings.Repeat("a", n), r.String())
+			assert.LessOrEqual(t, r.Balance().Depth(), maxBalancedDepth(n))
+		})
+	}
+}
+
+func TestRope_BalanceCoalesces(t *testing.T) {
+	var r Rope = newLeaf([]rune("a"))
+	for i := 1; i < 10_000; i++ {
+		// build directly from nodes, bypassing the automatic rebalancing
+		r = newNode(r, newLeaf([]rune("a")))
+	}
+	balanced := r.Balance()
+	assert.Equal(t, r.String(), balanced.String())
+	assert.LessOrEqual(t, len(balanced.leaves()), 10_000/defaultOptions.MinLeafSize+1)
+	assert.LessOrEqual(t, balanced.Depth(), maxBalancedDepth(10_000))
+	assert.Equal(t, 10_000, r.Depth(), "original tree changed")
+}
+
+func BenchmarkRope_Append(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		r := FromString("")
+		for j := 0; j < 1_000_000; j++ {
+			r = r.Append(FromRune('a'))
+		}
+		b.ReportMetric(float64(r.Depth()), "depth")
+	}
+}
+
+func BenchmarkRope_Prepend(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		r := FromString("")
+		for j := 0; j < 1_000_000; j++ {
+			r = r.Prepend(FromRune('a'))
+		}
+		b.ReportMetric(float64(r.Depth()), "depth")
+	}
+}
+
+func BenchmarkRope_Insert(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		r := FromString("")
+		for j := 0; j < 1_000_000; j++ {
+			r = r.Insert(rng.Intn(r.Length()+1), FromRune('a'))
+		}
+		b.ReportMetric(float64(r.Depth()), "depth")
+	}
+}
+
+func BenchmarkNode_Balance(b *testing.B) {
+	var r Rope = newLeaf([]rune("a"))
+	for i := 1; i < 100_000; i++ {
+		r = newNode(r, newLeaf([]rune("a")))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.ReportMetric(float64(r.Balance().Depth()), "depth")
+	}
+}
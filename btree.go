@@ -0,0 +1,567 @@
+package rope
+
+import (
+	"io"
+	"iter"
+	"regexp"
+	"slices"
+)
+
+var _ Rope = (*BTreeNode)(nil)
+
+// maxChildren is the default maximum number of children of a BTreeNode.
+const maxChildren = 16
+
+// BTreeNode is an internal node of a B-tree, which holds ropes created with BackendBTree. It has up to MaxChildren
+// children, which are either all leaves or all nodes of the same depth, and caches the totals of its subtree.
+type BTreeNode struct {
+	children    []Rope
+	length      int
+	lineBreaks  lineBreaks
+	byteLength  int
+	utf16Length int
+	depth       int
+	opts        *Options
+}
+
+// newBTreeNode creates a node which takes ownership of the children, which must be of the same depth.
+func (o *Options) newBTreeNode(children []Rope) Rope {
+	n := &BTreeNode{
+		children: children,
+		depth:    children[0].Depth() + 1,
+		opts:     o,
+	}
+	for _, c := range children {
+		n.length += c.Length()
+		n.byteLength += c.ByteLength()
+		n.utf16Length += c.UTF16Length()
+	}
+	n.lineBreaks = joinBreaks(children...)
+	return n
+}
+
+// btreeBuild assembles leaves into a B-tree, grouping each level as evenly as possible.
+func (o *Options) btreeBuild(level []Rope) Rope {
+	for len(level) > 1 {
+		groups := (len(level) + o.MaxChildren - 1) / o.MaxChildren
+		next := make([]Rope, 0, groups)
+		for i := 0; i < groups; i++ {
+			next = append(next, o.newBTreeNode(slices.Clone(level[i*len(level)/groups:(i+1)*len(level)/groups])))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// btreeJoin concatenates two B-trees, dropping empty sides and removing any nodes with a single child from the top
+// of the result.
+func (o *Options) btreeJoin(l, r Rope) Rope {
+	var joined Rope
+	switch {
+	case l.Length() == 0:
+		joined = r
+	case r.Length() == 0:
+		joined = l
+	default:
+		joined = o.btreeConcat(l, r)
+	}
+	for joined.childCount() == 1 {
+		joined = joined.child(0)
+	}
+	return joined
+}
+
+// btreeConcat concatenates two non-empty B-trees. The shallower tree is merged into the edge of the deeper one, and
+// nodes which overflow are split, so that every leaf of the result is at the same depth.
+func (o *Options) btreeConcat(l, r Rope) Rope {
+	ld, rd := l.Depth(), r.Depth()
+	switch {
+	case ld < rd:
+		children := btreeChildren(r)
+		if ld == rd-1 && o.btreeFull(l) {
+			return o.btreeMerge([]Rope{l}, children)
+		}
+		joined := o.btreeConcat(l, children[0])
+		if joined.Depth() == rd-1 {
+			return o.btreeMerge([]Rope{joined}, children[1:])
+		}
+		return o.btreeMerge(btreeChildren(joined), children[1:])
+	case ld > rd:
+		children := btreeChildren(l)
+		if rd == ld-1 && o.btreeFull(r) {
+			return o.btreeMerge(children, []Rope{r})
+		}
+		last := len(children) - 1
+		joined := o.btreeConcat(children[last], r)
+		if joined.Depth() == ld-1 {
+			return o.btreeMerge(children[:last], []Rope{joined})
+		}
+		return o.btreeMerge(children[:last], btreeChildren(joined))
+	case o.btreeFull(l) && o.btreeFull(r):
+		return o.newBTreeNode([]Rope{l, r})
+	case ld == 1:
+		return o.btreeMergeLeaves(l, r)
+	default:
+		return o.btreeMerge(btreeChildren(l), btreeChildren(r))
+	}
+}
+
+// btreeFull reports whether a tree is full enough to be a child in a B-tree without being merged with its
+// neighbours: a leaf must hold at least MinLeafSize runes, and a node at least half of MaxChildren children. Lazy
+// leaves are always full, as merging them would read and copy their text.
+func (o *Options) btreeFull(r Rope) bool {
+	if isLazy(r) {
+		return true
+	}
+	if r.childCount() == 0 {
+		return r.Length() >= o.MinLeafSize
+	}
+	return r.childCount() >= max(o.MaxChildren/2, 1)
+}
+
+// btreeMerge creates a node holding the children of two nodes, or two nodes under a new parent if there are more
+// than MaxChildren of them.
+func (o *Options) btreeMerge(l, r []Rope) Rope {
+	children := slices.Concat(l, r)
+	if len(children) <= o.MaxChildren {
+		return o.newBTreeNode(children)
+	}
+	at := min(o.MaxChildren, len(children)-max(o.MaxChildren/2, 1))
+	return o.newBTreeNode([]Rope{
+		o.newBTreeNode(children[:at:at]),
+		o.newBTreeNode(children[at:]),
+	})
+}
+
+// btreeMergeLeaves joins two leaves, at least one of which is too small to stand alone, into one leaf or two
+// evenly sized ones. They are kept as they are if their text cannot be held in one leaf, or if either is lazy.
+func (o *Options) btreeMergeLeaves(l, r Rope) Rope {
+	if isLazy(l) || isLazy(r) {
+		return o.newBTreeNode([]Rope{l, r})
+	}
+	joined, ok := o.mergeLeaves(l, r)
+	if !ok {
+		return o.newBTreeNode([]Rope{l, r})
+	}
+	if joined.Length() <= o.MaxLeafSize {
+		return joined
+	}
+	left, right := joined.Split(joined.Length() / 2)
+	return o.newBTreeNode([]Rope{left, right})
+}
+
+// btreeChildren returns the children of a node.
+func btreeChildren(r Rope) []Rope {
+	if n, ok := r.(*BTreeNode); ok {
+		return n.children
+	}
+	children := make([]Rope, r.childCount())
+	for i := range children {
+		children[i] = r.child(i)
+	}
+	return children
+}
+
+func (n BTreeNode) Options() Options {
+	return *n.options()
+}
+
+// options returns the options of the node, which are the defaults for the zero value.
+func (n BTreeNode) options() *Options {
+	if n.opts == nil {
+		return defaultOptions
+	}
+	return n.opts
+}
+
+// locate returns the index of the child holding the rune at index i, and the index of the rune within it. Indexes
+// past the end are located in the last child. If end is true, an index on the boundary between two children is
+// located at the end of the first.
+func (n BTreeNode) locate(i int, end bool) (int, int) {
+	last := len(n.children) - 1
+	for k, c := range n.children[:last] {
+		if i < c.Length() || (end && i == c.Length()) {
+			return k, i
+		}
+		i -= c.Length()
+	}
+	return last, i
+}
+
+// with returns a copy of the node with the child at index k replaced.
+func (n BTreeNode) with(k int, child Rope) Rope {
+	children := slices.Clone(n.children)
+	children[k] = child
+	return n.options().newBTreeNode(children)
+}
+
+func (n BTreeNode) String() string {
+	return leafText(&n)
+}
+
+func (n BTreeNode) Length() int {
+	return n.length
+}
+
+func (n BTreeNode) At(i int) rune {
+	k, i := n.locate(i, false)
+	return n.children[k].At(i)
+}
+
+func (n BTreeNode) Append(r Rope) Rope {
+	o := n.options()
+	return o.join(&n, o.adopt(r))
+}
+
+func (n BTreeNode) Prepend(r Rope) Rope {
+	o := n.options()
+	return o.join(o.adopt(r), &n)
+}
+
+func (n BTreeNode) Split(at int) (Rope, Rope) {
+	o := n.options()
+	at = min(max(at, 0), n.length)
+	k, i := n.locate(at, false)
+	left, right := n.children[k].Split(i)
+	if k > 0 {
+		left = o.join(o.newBTreeNode(slices.Clone(n.children[:k])), left)
+	}
+	if k < len(n.children)-1 {
+		right = o.join(right, o.newBTreeNode(slices.Clone(n.children[k+1:])))
+	}
+	return left, right
+}
+
+func (n BTreeNode) Sub(start, end int) Rope {
+	start = max(start, 0)
+	end = min(end, n.length)
+	if start >= end {
+		return n.options().newLeaf("")
+	}
+	_, right := n.Split(start)
+	left, _ := right.Split(end - start)
+	return left
+}
+
+func (n BTreeNode) Index(r rune) int {
+	var offset int
+	for _, c := range n.children {
+		if index := c.Index(r); index >= 0 {
+			return offset + index
+		}
+		offset += c.Length()
+	}
+	return -1
+}
+
+func (n BTreeNode) LastIndex(r rune) int {
+	offset := n.length
+	for k := len(n.children) - 1; k >= 0; k-- {
+		offset -= n.children[k].Length()
+		if index := n.children[k].LastIndex(r); index >= 0 {
+			return offset + index
+		}
+	}
+	return -1
+}
+
+func (n BTreeNode) Line(l int) Rope {
+	start := n.OffsetOfLine(l)
+	if start < 0 {
+		return n.options().newLeaf("")
+	}
+	end := n.OffsetOfLine(l + 1)
+	if end < 0 {
+		end = n.length
+	} else {
+		// exclude the line break
+		end = lineEnd(&n, start, end)
+	}
+	return n.Sub(start, end)
+}
+
+func (n BTreeNode) NewLineCount() int {
+	return n.lineBreaks.count()
+}
+
+func (n BTreeNode) breaks() lineBreaks {
+	return n.lineBreaks
+}
+
+// Balance returns the node unchanged, as a B-tree is always balanced.
+func (n BTreeNode) Balance() Rope {
+	return &n
+}
+
+func (n BTreeNode) Depth() int {
+	return n.depth
+}
+
+func (n BTreeNode) Data() []rune {
+	return treeRunes(&n)
+}
+
+func (n BTreeNode) leaves() []Rope {
+	var leaves []Rope
+	for _, c := range n.children {
+		leaves = append(leaves, c.leaves()...)
+	}
+	return leaves
+}
+
+func (n BTreeNode) childCount() int {
+	return len(n.children)
+}
+
+func (n BTreeNode) child(i int) Rope {
+	return n.children[i]
+}
+
+func (n BTreeNode) Insert(at int, r Rope) Rope {
+	o := n.options()
+	r = o.adopt(r)
+	if r.Length() == 0 {
+		return &n
+	}
+	at = min(max(at, 0), n.length)
+	k, i := n.locate(at, true)
+	old := n.children[k]
+	inserted := old.Insert(i, r)
+	switch inserted.Depth() - old.Depth() {
+	case 0:
+		// the child absorbed the insertion
+		return n.with(k, inserted)
+	case 1:
+		// the child split, so take its children in its place, splitting this node in turn if it overflows
+		children := btreeChildren(inserted)
+		return o.btreeMerge(slices.Concat(n.children[:k], children[:len(children)-1]),
+			slices.Concat(children[len(children)-1:], n.children[k+1:]))
+	default:
+		left, right := n.Split(at)
+		return o.join(o.join(left, r), right)
+	}
+}
+
+func (n BTreeNode) InsertString(at int, s string) Rope {
+	return n.Insert(at, n.options().fromString(s))
+}
+
+func (n BTreeNode) Delete(start, end int) Rope {
+	o := n.options()
+	start = max(start, 0)
+	end = min(end, n.length)
+	if start >= end {
+		return &n
+	}
+	k, i := n.locate(start, false)
+	old := n.children[k]
+	if i+end-start < old.Length() {
+		// the deletion is within one child, which can be replaced unless it changes depth
+		if deleted := old.Delete(i, i+end-start); deleted.Depth() == old.Depth() {
+			return n.with(k, deleted)
+		}
+	}
+	left, _ := n.Split(start)
+	_, right := n.Split(end)
+	return o.join(left, right)
+}
+
+func (n BTreeNode) ByteLength() int {
+	return n.byteLength
+}
+
+func (n BTreeNode) RuneToByte(i int) int {
+	var offset int
+	k, i := n.locate(i, false)
+	for _, c := range n.children[:k] {
+		offset += c.ByteLength()
+	}
+	return offset + n.children[k].RuneToByte(i)
+}
+
+func (n BTreeNode) ByteToRune(b int) int {
+	var offset int
+	last := len(n.children) - 1
+	for _, c := range n.children[:last] {
+		if b < c.ByteLength() {
+			return offset + c.ByteToRune(b)
+		}
+		b -= c.ByteLength()
+		offset += c.Length()
+	}
+	return offset + n.children[last].ByteToRune(b)
+}
+
+func (n BTreeNode) SplitAtByte(b int) (Rope, Rope) {
+	return n.Split(n.ByteToRune(b))
+}
+
+func (n BTreeNode) OffsetOfLine(l int) int {
+	if l < 0 {
+		return -1
+	}
+	var before breakCounter
+	var offset int
+	for _, c := range n.children {
+		if c.Length() == 0 {
+			continue
+		}
+		lines := before.breaks.count()
+		if before.breaks.lastCR && c.breaks().firstLF {
+			// the "\r\n" split between the children is a single line break
+			lines--
+		}
+		// a line which starts after a "\r" ending the child may instead start after a "\n" beginning the next
+		if k := l - lines; k < c.NewLineCount() || (k == c.NewLineCount() && !c.breaks().lastCR) {
+			index := c.OffsetOfLine(k)
+			if index < 0 {
+				return -1
+			}
+			return offset + index
+		}
+		before.add(c)
+		offset += c.Length()
+	}
+	if before.breaks.lastCR && l == before.breaks.count() {
+		return offset
+	}
+	return -1
+}
+
+func (n BTreeNode) LineColumn(i int) (int, int) {
+	k, j := n.locate(i, false)
+	before := joinBreaks(n.children[:k]...)
+	lines := before.count()
+	line, col := n.children[k].LineColumn(j)
+	if before.lastCR && n.children[k].breaks().firstLF {
+		if line == 0 {
+			// the "\n" of a "\r\n" is on the same line as the "\r"
+			line, col := n.LineColumn(i - j - 1)
+			return line, col + 1
+		}
+		return lines + line - 1, col
+	}
+	if line > 0 {
+		return lines + line, col
+	}
+	// the line started in an earlier child
+	for j := k - 1; j >= 0; j-- {
+		c := n.children[j]
+		if c.NewLineCount() > 0 {
+			_, start := c.LineColumn(c.Length())
+			return lines, start + col
+		}
+		col += c.Length()
+	}
+	return lines, col
+}
+
+func (n BTreeNode) Offset(line, col int) int {
+	return offset(&n, line, col)
+}
+
+func (n BTreeNode) UTF16Length() int {
+	return n.utf16Length
+}
+
+func (n BTreeNode) RuneToUTF16(i int) int {
+	var offset int
+	k, i := n.locate(i, false)
+	for _, c := range n.children[:k] {
+		offset += c.UTF16Length()
+	}
+	return offset + n.children[k].RuneToUTF16(i)
+}
+
+func (n BTreeNode) UTF16ToRune(u int) int {
+	var offset int
+	last := len(n.children) - 1
+	for _, c := range n.children[:last] {
+		if u < c.UTF16Length() {
+			return offset + c.UTF16ToRune(u)
+		}
+		u -= c.UTF16Length()
+		offset += c.Length()
+	}
+	return offset + n.children[last].UTF16ToRune(u)
+}
+
+func (n BTreeNode) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(&n, w)
+}
+
+func (n BTreeNode) IterAt(i int) *Iterator {
+	return newIterator(&n, i)
+}
+
+func (n BTreeNode) Runes() iter.Seq2[int, rune] {
+	return runesFrom(&n, 0)
+}
+
+func (n BTreeNode) RunesFrom(i int) iter.Seq2[int, rune] {
+	return runesFrom(&n, i)
+}
+
+func (n BTreeNode) Lines(from, to int) *LineIterator {
+	return newLineIterator(&n, from, to)
+}
+
+func (n BTreeNode) Chunks() iter.Seq[string] {
+	return textInRange(&n, 0, n.length)
+}
+
+func (n BTreeNode) ChunksInRange(start, end int) iter.Seq[string] {
+	return textInRange(&n, start, end)
+}
+
+func (n BTreeNode) IndexString(s string) int {
+	return indexFrom(&n, s, 0)
+}
+
+func (n BTreeNode) IndexFrom(s string, from int) int {
+	return indexFrom(&n, s, from)
+}
+
+func (n BTreeNode) LastIndexString(s string) int {
+	return lastIndex(&n, s)
+}
+
+func (n BTreeNode) Count(s string) int {
+	return count(&n, s)
+}
+
+func (n BTreeNode) FindRegexp(re *regexp.Regexp, from int) []int {
+	return findRegexp(&n, re, from)
+}
+
+func (n BTreeNode) FindAllRegexp(re *regexp.Regexp, limit int) [][]int {
+	return findAllRegexp(&n, re, limit)
+}
+
+func (n BTreeNode) InvalidRanges() [][]int {
+	return invalidRanges(&n)
+}
+
+func (n BTreeNode) LineEnding() LineEnding {
+	return lineEnding(&n)
+}
+
+func (n BTreeNode) LineEndings() LineEndingReport {
+	return lineEndings(&n)
+}
+
+func (n BTreeNode) NormalizeLineEndings(e LineEnding) Rope {
+	return normalizeLineEndings(&n, e)
+}
+
+func (n BTreeNode) Replace(old, new string, limit int) (Rope, []Edit) {
+	return replace(&n, old, new, limit)
+}
+
+func (n BTreeNode) ReplaceAll(old, new string) (Rope, []Edit) {
+	return replace(&n, old, new, -1)
+}
+
+func (n BTreeNode) ReplaceRegexp(re *regexp.Regexp, template string) (Rope, []Edit) {
+	return replaceRegexp(&n, re, template)
+}
@@ -0,0 +1,51 @@
+package rope
+
+import "iter"
+
+// textInRange returns an iterator over the UTF-8 encoding of the leaves of a tree between the given start and end
+// indexes, without copying it. Subtrees outside the range are skipped without visiting their leaves.
+func textInRange(r Rope, start, end int) iter.Seq[string] {
+	if start < 0 {
+		start = 0
+	}
+	return func(yield func(string) bool) {
+		walkText(r, start, end, yield)
+	}
+}
+
+// walkText yields the text of the leaves of a tree between the given start and end indexes, returning false if
+// yield asked to stop.
+func walkText(r Rope, start, end int, yield func(string) bool) bool {
+	count := r.childCount()
+	if count == 0 {
+		length := r.Length()
+		from, to := max(start, 0), min(end, length)
+		if from >= to {
+			return true
+		}
+		text := leafString(r)
+		return yield(text[byteOffset(text, length, from):byteOffset(text, length, to)])
+	}
+	var offset int
+	for i := 0; i < count && offset < end; i++ {
+		c := r.child(i)
+		length := c.Length()
+		if offset+length > start && !walkText(c, start-offset, end-offset, yield) {
+			return false
+		}
+		offset += length
+	}
+	return true
+}
+
+// treeRunes returns the runes of a tree, decoding the text of each leaf separately so that invalid bytes at the end
+// of one leaf are never joined with those at the start of the next.
+func treeRunes(r Rope) []rune {
+	runes := make([]rune, 0, r.Length())
+	for text := range textInRange(r, 0, r.Length()) {
+		for _, c := range text {
+			runes = append(runes, c)
+		}
+	}
+	return runes
+}
@@ -0,0 +1,137 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRope_Chunks(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		want []string
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			want: nil,
+		},
+		{
+			name: "leaf",
+			rope: FromString("abc"),
+			want: []string{"abc"},
+		},
+		{
+			name: "node",
+			rope: testIteratorRope(),
+			want: []string{"ab", "cé", "€😀", "f", "g"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for chunk := range tt.rope.Chunks() {
+				got = append(got, chunk)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRope_ChunksInRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		rope       Rope
+		start, end int
+		want       []string
+	}{
+		{
+			name:  "empty",
+			rope:  FromString(""),
+			start: 0,
+			end:   10,
+			want:  nil,
+		},
+		{
+			name:  "leaf",
+			rope:  FromString("abcdef"),
+			start: 1,
+			end:   4,
+			want:  []string{"bcd"},
+		},
+		{
+			name:  "across leaves",
+			rope:  testIteratorRope(),
+			start: 1,
+			end:   5,
+			want:  []string{"b", "cé", "€"},
+		},
+		{
+			name:  "leaf boundaries",
+			rope:  testIteratorRope(),
+			start: 2,
+			end:   6,
+			want:  []string{"cé", "€😀"},
+		},
+		{
+			name:  "out of range",
+			rope:  testIteratorRope(),
+			start: -1,
+			end:   20,
+			want:  []string{"ab", "cé", "€😀", "f", "g"},
+		},
+		{
+			name:  "end < start",
+			rope:  testIteratorRope(),
+			start: 4,
+			end:   2,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for chunk := range tt.rope.ChunksInRange(tt.start, tt.end) {
+				got = append(got, chunk)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRope_Chunks_ZeroCopy(t *testing.T) {
+	r := newNode(FromString("abc"), FromString("dé€"))
+	var texts []string
+	var leaves []*byte
+	for _, leaf := range r.leaves() {
+		texts = append(texts, leaf.String())
+		leaves = append(leaves, unsafe.StringData(leaf.String()))
+	}
+	// the chunks are the text of the leaves, rather than copies of it
+	var chunks []*byte
+	for chunk := range r.Chunks() {
+		chunks = append(chunks, unsafe.StringData(chunk))
+	}
+	assert.Equal(t, leaves, chunks)
+	for chunk := range r.ChunksInRange(4, 6) {
+		assert.Equal(t, "é€", chunk)
+		assert.Equal(t, unsafe.StringData(texts[1][1:]), unsafe.StringData(chunk))
+	}
+}
+
+func TestRope_ChunksInRange_Allocations(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			r := FromStringWithOptions(strings.Repeat("héllo wörld\n", 1<<16), backend.options)
+			// only the leaves in the range are visited
+			assert.Less(t, testing.AllocsPerRun(10, func() {
+				for chunk := range r.ChunksInRange(1000, 1010) {
+					_ = chunk
+				}
+			}), float64(20))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package rope
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testBackends are options selecting each backend. Most use small leaves and nodes, so that even short ropes are
+// held in deep trees.
+var testBackends = []struct {
+	name    string
+	options Options
+}{
+	{name: "binary", options: Options{Backend: BackendBinary, MaxLeafSize: 4}},
+	{name: "b-tree", options: Options{Backend: BackendBTree, MaxLeafSize: 4, MaxChildren: 3}},
+	{name: "wide b-tree", options: Options{Backend: BackendBTree, MaxLeafSize: 8, MaxChildren: 16}},
+	{name: "default b-tree", options: Options{Backend: BackendBTree}},
+}
+
+// TestRope_Conformance applies random edits to ropes of each backend, checking that every method gives the same
+// result as it does for a single leaf holding the expected runes.
+func TestRope_Conformance(t *testing.T) {
+	for _, backend := range testBackends {
+		for seed := int64(0); seed < 3; seed++ {
+			t.Run(fmt.Sprintf("%s seed %d", backend.name, seed), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(seed))
+				text := func() string {
+					runes := make([]rune, rng.Intn(30))
+					for i := range runes {
+						runes[i] = []rune("ab€😀\n\r")[rng.Intn(6)]
+					}
+					return string(runes)
+				}
+
+				v := version{rope: FromStringWithOptions(text(), backend.options)}
+				v.want = []rune(v.rope.String())
+				var ops []string
+				for i := 0; i < 100; i++ {
+					var op string
+					v, op = randomEdit(rng, v, text)
+					ops = append(ops, op)
+					requireConformance(t, rng, v.rope, newLeaf(v.want), fmt.Sprintf("after %v", ops))
+				}
+			})
+		}
+	}
+}
+
+// requireConformance checks that every method of a rope gives the same result as it does for the reference, drawing
+// the ranges it checks from rng.
+func requireConformance(t *testing.T, rng *rand.Rand, r, ref Rope, msg string) {
+	t.Helper()
+	requireStructure(t, r, msg)
+
+	require.Equal(t, ref.String(), r.String(), msg)
+	require.Equal(t, ref.Length(), r.Length(), msg)
+	require.Equal(t, string(ref.Data()), string(r.Data()), msg)
+	require.Equal(t, ref.NewLineCount(), r.NewLineCount(), msg)
+	require.Equal(t, ref.ByteLength(), r.ByteLength(), msg)
+	require.Equal(t, ref.UTF16Length(), r.UTF16Length(), msg)
+	require.Equal(t, ref.LineEnding(), r.LineEnding(), msg)
+	require.Equal(t, ref.LineEndings(), r.LineEndings(), msg)
+	require.Equal(t, ref.NormalizeLineEndings(LineEndingCRLF).String(), r.NormalizeLineEndings(LineEndingCRLF).String(), msg)
+	require.Equal(t, r.Options(), r.Balance().Options(), msg)
+	require.Equal(t, ref.String(), r.Balance().String(), msg)
+
+	for i := -1; i <= ref.Length()+1; i++ {
+		require.Equal(t, ref.At(i), r.At(i), "At(%d) %s", i, msg)
+		require.Equal(t, ref.RuneToByte(i), r.RuneToByte(i), "RuneToByte(%d) %s", i, msg)
+		require.Equal(t, ref.RuneToUTF16(i), r.RuneToUTF16(i), "RuneToUTF16(%d) %s", i, msg)
+		wantLine, wantCol := ref.LineColumn(i)
+		gotLine, gotCol := r.LineColumn(i)
+		require.Equal(t, []int{wantLine, wantCol}, []int{gotLine, gotCol}, "LineColumn(%d) %s", i, msg)
+
+		wantLeft, wantRight := ref.Split(i)
+		gotLeft, gotRight := r.Split(i)
+		require.Equal(t, wantLeft.String(), gotLeft.String(), "Split(%d) %s", i, msg)
+		require.Equal(t, wantRight.String(), gotRight.String(), "Split(%d) %s", i, msg)
+		requireStructure(t, gotLeft, msg)
+		requireStructure(t, gotRight, msg)
+
+		it := r.IterAt(i)
+		var got []rune
+		for c, ok := it.Next(); ok; c, ok = it.Next() {
+			got = append(got, c)
+		}
+		require.Equal(t, string(ref.Data()[min(max(i, 0), ref.Length()):]), string(got), "IterAt(%d) %s", i, msg)
+	}
+	for b := -1; b <= ref.ByteLength()+1; b++ {
+		require.Equal(t, ref.ByteToRune(b), r.ByteToRune(b), "ByteToRune(%d) %s", b, msg)
+	}
+	for u := -1; u <= ref.UTF16Length()+1; u++ {
+		require.Equal(t, ref.UTF16ToRune(u), r.UTF16ToRune(u), "UTF16ToRune(%d) %s", u, msg)
+	}
+	for l := -1; l <= ref.NewLineCount()+1; l++ {
+		require.Equal(t, ref.Line(l).String(), r.Line(l).String(), "Line(%d) %s", l, msg)
+		require.Equal(t, ref.OffsetOfLine(l), r.OffsetOfLine(l), "OffsetOfLine(%d) %s", l, msg)
+		require.Equal(t, ref.Offset(l, 2), r.Offset(l, 2), "Offset(%d, 2) %s", l, msg)
+	}
+	for i := 0; i < 10; i++ {
+		start := rng.Intn(ref.Length()+2) - 1
+		end := start + rng.Intn(ref.Length()+2-start)
+		require.Equal(t, ref.Sub(start, end).String(), r.Sub(start, end).String(), "Sub(%d, %d) %s", start, end, msg)
+		require.Equal(t, ref.Delete(start, end).String(), r.Delete(start, end).String(), "Delete(%d, %d) %s", start, end, msg)
+		var want, got strings.Builder
+		for chunk := range ref.ChunksInRange(start, end) {
+			want.WriteString(chunk)
+		}
+		for chunk := range r.ChunksInRange(start, end) {
+			got.WriteString(chunk)
+		}
+		require.Equal(t, want.String(), got.String(), "ChunksInRange(%d, %d) %s", start, end, msg)
+	}
+
+	for _, c := range "ab€😀\n\rz" {
+		require.Equal(t, ref.Index(c), r.Index(c), "Index(%q) %s", c, msg)
+		require.Equal(t, ref.LastIndex(c), r.LastIndex(c), "LastIndex(%q) %s", c, msg)
+	}
+	for _, s := range []string{"a😀", "\r\n", "bb"} {
+		require.Equal(t, ref.IndexString(s), r.IndexString(s), "IndexString(%q) %s", s, msg)
+		require.Equal(t, ref.IndexFrom(s, ref.Length()/2), r.IndexFrom(s, ref.Length()/2), "IndexFrom(%q) %s", s, msg)
+		require.Equal(t, ref.LastIndexString(s), r.LastIndexString(s), "LastIndexString(%q) %s", s, msg)
+		require.Equal(t, ref.Count(s), r.Count(s), "Count(%q) %s", s, msg)
+	}
+	re := regexp.MustCompile(`a+|€\n?`)
+	require.Equal(t, ref.FindAllRegexp(re, -1), r.FindAllRegexp(re, -1), msg)
+
+	var wantLines, gotLines []string
+	for _, line := range ref.Lines(0, ref.NewLineCount()+1).All() {
+		wantLines = append(wantLines, line.String())
+	}
+	for _, line := range r.Lines(0, ref.NewLineCount()+1).All() {
+		gotLines = append(gotLines, line.String())
+	}
+	require.Equal(t, wantLines, gotLines, msg)
+
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, ref.String(), buf.String(), msg)
+}
+
+// requireStructure checks the invariants of a tree: the cached totals of every node match its children, and the
+// children of every BTreeNode are within MaxChildren and all of the same depth.
+func requireStructure(t *testing.T, r Rope, msg string) {
+	t.Helper()
+	if r.childCount() == 0 {
+		require.Equal(t, 1, r.Depth(), msg)
+		return
+	}
+	var length, bytes, utf16, depth int
+	var lines breakCounter
+	for i := 0; i < r.childCount(); i++ {
+		c := r.child(i)
+		requireStructure(t, c, msg)
+		length += c.Length()
+		lines.add(c)
+		bytes += c.ByteLength()
+		utf16 += c.UTF16Length()
+		depth = max(depth, c.Depth())
+		if _, ok := r.(*BTreeNode); ok {
+			require.Equal(t, r.child(0).Depth(), c.Depth(), "uneven b-tree %s", msg)
+		}
+	}
+	if _, ok := r.(*BTreeNode); ok {
+		require.LessOrEqual(t, r.childCount(), r.Options().MaxChildren, msg)
+	}
+	require.Equal(t, []int{length, lines.breaks.count(), bytes, utf16, depth + 1},
+		[]int{r.Length(), r.NewLineCount(), r.ByteLength(), r.UTF16Length(), r.Depth()}, msg)
+}
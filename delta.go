@@ -0,0 +1,305 @@
+package rope
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidOp is returned when a Delta contains an operation which does not have exactly one of Retain,
+	// Insert or Delete set to a positive length.
+	ErrInvalidOp = errors.New("invalid delta operation")
+	// ErrDeltaOutOfRange is returned when a Delta retains or deletes past the end of the rope it is applied to.
+	ErrDeltaOutOfRange = errors.New("delta extends past the end of the rope")
+)
+
+// Op is a single operation of a Delta. Exactly one of Retain, Insert and Delete should be set.
+type Op struct {
+	Retain int    `json:"retain,omitempty"`
+	Insert string `json:"insert,omitempty"`
+	Delete int    `json:"delete,omitempty"`
+}
+
+// Delta describes an edit as data: a sequence of operations which walk a rope from start to end, retaining,
+// inserting and deleting runes. All lengths are measured in runes, and any runes after the last operation are
+// retained. A Delta serialises to JSON as an array of operations, e.g. [{"retain":5},{"insert":"abc"}].
+//
+// Deltas are built with Retain, Insert and Delete, which merge adjacent operations of the same kind. As with
+// append, their result may share memory with the receiver, which should not be used afterwards.
+type Delta []Op
+
+// Retain returns the delta with an operation retaining n runes appended.
+func (d Delta) Retain(n int) Delta {
+	if n <= 0 {
+		return d
+	}
+	if last := len(d) - 1; last >= 0 && d[last].Retain > 0 {
+		d[last].Retain += n
+		return d
+	}
+	return append(d, Op{Retain: n})
+}
+
+// Insert returns the delta with an operation inserting s appended.
+// Insertions are kept before any deletions at the same position, so that equivalent deltas are equal.
+func (d Delta) Insert(s string) Delta {
+	if s == "" {
+		return d
+	}
+	last := len(d) - 1
+	if last >= 0 && d[last].Delete > 0 {
+		// insert before the trailing delete
+		del := d[last]
+		return append(d[:last].Insert(s), del)
+	}
+	if last >= 0 && d[last].Insert != "" {
+		d[last].Insert += s
+		return d
+	}
+	return append(d, Op{Insert: s})
+}
+
+// Delete returns the delta with an operation deleting n runes appended.
+func (d Delta) Delete(n int) Delta {
+	if n <= 0 {
+		return d
+	}
+	if last := len(d) - 1; last >= 0 && d[last].Delete > 0 {
+		d[last].Delete += n
+		return d
+	}
+	return append(d, Op{Delete: n})
+}
+
+// push appends an operation, merging it with the previous one where possible.
+func (d Delta) push(op Op) Delta {
+	switch {
+	case op.Retain > 0:
+		return d.Retain(op.Retain)
+	case op.Insert != "":
+		return d.Insert(op.Insert)
+	default:
+		return d.Delete(op.Delete)
+	}
+}
+
+// chop removes a trailing retain, which has no effect.
+func (d Delta) chop() Delta {
+	if last := len(d) - 1; last >= 0 && d[last].Retain > 0 {
+		return d[:last]
+	}
+	return d
+}
+
+// Apply applies the delta to a rope, returning the new rope.
+func (d Delta) Apply(r Rope) (Rope, error) {
+	var pos int
+	for i, op := range d {
+		if err := op.validate(); err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+		switch {
+		case op.Retain > 0:
+			pos += op.Retain
+			if pos > r.Length() {
+				return nil, fmt.Errorf("op %d: %w", i, ErrDeltaOutOfRange)
+			}
+		case op.Insert != "":
+			r = r.InsertString(pos, op.Insert)
+			pos += utf8.RuneCountInString(op.Insert)
+		default:
+			if pos+op.Delete > r.Length() {
+				return nil, fmt.Errorf("op %d: %w", i, ErrDeltaOutOfRange)
+			}
+			r = r.Delete(pos, pos+op.Delete)
+		}
+	}
+	return r, nil
+}
+
+// edits returns the delta as a list of edits positioned against the rope it applies to.
+func (d Delta) edits() []Edit {
+	var edits []Edit
+	var pos int
+	for _, op := range d {
+		switch {
+		case op.Retain > 0:
+			pos += op.Retain
+		case op.Insert != "":
+			edits = append(edits, Edit{Start: pos, End: pos, Text: op.Insert})
+		default:
+			if last := len(edits) - 1; last >= 0 && edits[last].End == pos {
+				// the deletion follows an insertion at the same position
+				edits[last].End += op.Delete
+			} else {
+				edits = append(edits, Edit{Start: pos, End: pos + op.Delete})
+			}
+			pos += op.Delete
+		}
+	}
+	return edits
+}
+
+// Invert returns a delta which undoes the delta when applied to its result. The base rope is the rope the delta
+// applies to, from which the deleted text is recovered.
+func (d Delta) Invert(base Rope) Delta {
+	var inverse Delta
+	var pos int
+	for _, op := range d {
+		switch {
+		case op.Retain > 0:
+			inverse = inverse.Retain(op.Retain)
+			pos += op.Retain
+		case op.Insert != "":
+			inverse = inverse.Delete(utf8.RuneCountInString(op.Insert))
+		case op.Delete > 0:
+			inverse = inverse.Insert(base.Sub(pos, pos+op.Delete).String())
+			pos += op.Delete
+		}
+	}
+	return inverse.chop()
+}
+
+func (op Op) validate() error {
+	var set int
+	if op.Retain != 0 {
+		set++
+	}
+	if op.Insert != "" {
+		set++
+	}
+	if op.Delete != 0 {
+		set++
+	}
+	if set != 1 || op.Retain < 0 || op.Delete < 0 {
+		return ErrInvalidOp
+	}
+	return nil
+}
+
+// Compose returns a single delta with the same effect as applying a and then b.
+func Compose(a, b Delta) Delta {
+	ai, bi := newOpIterator(a), newOpIterator(b)
+	var out Delta
+	for ai.hasNext() || bi.hasNext() {
+		switch {
+		case bi.peekInsert():
+			out = out.push(bi.next(math.MaxInt))
+		case ai.peekDelete():
+			out = out.push(ai.next(math.MaxInt))
+		default:
+			length := min(ai.peekLength(), bi.peekLength())
+			aOp, bOp := ai.next(length), bi.next(length)
+			if bOp.Retain > 0 {
+				out = out.push(aOp)
+			} else if aOp.Retain > 0 {
+				// b deletes what a retained; if a inserted it instead, the two cancel out
+				out = out.push(bOp)
+			}
+		}
+	}
+	return out.chop()
+}
+
+// Transform transforms two concurrent deltas which apply to the same rope, for operational transformation.
+// It returns a' and b' such that applying a then b' gives the same result as applying b then a'.
+// When both insert at the same position, the insertion from a is placed first.
+func Transform(a, b Delta) (Delta, Delta) {
+	return transform(b, a, false), transform(a, b, true)
+}
+
+// transform returns other transformed so that it can be applied after d. If priority is set, insertions from d
+// are placed before insertions from other at the same position.
+func transform(d, other Delta, priority bool) Delta {
+	di, oi := newOpIterator(d), newOpIterator(other)
+	var out Delta
+	for di.hasNext() || oi.hasNext() {
+		switch {
+		case di.peekInsert() && (priority || !oi.peekInsert()):
+			out = out.Retain(utf8.RuneCountInString(di.next(math.MaxInt).Insert))
+		case oi.peekInsert():
+			out = out.push(oi.next(math.MaxInt))
+		default:
+			length := min(di.peekLength(), oi.peekLength())
+			dOp, oOp := di.next(length), oi.next(length)
+			switch {
+			case dOp.Delete > 0:
+				// the runes are already deleted by d
+			case oOp.Delete > 0:
+				out = out.push(oOp)
+			default:
+				out = out.Retain(length)
+			}
+		}
+	}
+	return out.chop()
+}
+
+// opIterator walks the operations of a delta, splitting them as required.
+// Once the operations are exhausted, it behaves as an infinite retain.
+type opIterator struct {
+	ops    Delta
+	index  int
+	offset int // runes of the current operation already consumed
+}
+
+func newOpIterator(d Delta) *opIterator {
+	return &opIterator{
+		ops: d,
+	}
+}
+
+func (it *opIterator) hasNext() bool {
+	return it.index < len(it.ops)
+}
+
+func (it *opIterator) peekInsert() bool {
+	return it.hasNext() && it.ops[it.index].Insert != ""
+}
+
+func (it *opIterator) peekDelete() bool {
+	return it.hasNext() && it.ops[it.index].Delete > 0
+}
+
+func (it *opIterator) peekLength() int {
+	if !it.hasNext() {
+		return math.MaxInt
+	}
+	return opLength(it.ops[it.index]) - it.offset
+}
+
+// next consumes up to n runes of the current operation.
+func (it *opIterator) next(n int) Op {
+	if !it.hasNext() {
+		return Op{Retain: n}
+	}
+	op := it.ops[it.index]
+	n = min(n, opLength(op)-it.offset)
+	start := it.offset
+	it.offset += n
+	if it.offset == opLength(op) {
+		it.index++
+		it.offset = 0
+	}
+	switch {
+	case op.Retain > 0:
+		return Op{Retain: n}
+	case op.Insert != "":
+		return Op{Insert: string([]rune(op.Insert)[start : start+n])}
+	default:
+		return Op{Delete: n}
+	}
+}
+
+func opLength(op Op) int {
+	switch {
+	case op.Retain > 0:
+		return op.Retain
+	case op.Insert != "":
+		return utf8.RuneCountInString(op.Insert)
+	default:
+		return op.Delete
+	}
+}
@@ -0,0 +1,192 @@
+package rope
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomDelta generates a delta which applies to a rope of the given length.
+func randomDelta(rng *rand.Rand, length int) Delta {
+	var d Delta
+	for pos := 0; pos < length; {
+		n := 1 + rng.Intn(length-pos)
+		switch rng.Intn(3) {
+		case 0:
+			d = d.Retain(n)
+			pos += n
+		case 1:
+			d = d.Insert(string([]rune("xy€😀")[:1+rng.Intn(4)]))
+		default:
+			d = d.Delete(n)
+			pos += n
+		}
+	}
+	if rng.Intn(2) == 0 {
+		d = d.Insert("z")
+	}
+	return d
+}
+
+func TestDelta_Builder(t *testing.T) {
+	d := Delta{}.Retain(2).Retain(3).Delete(1).Insert("a").Insert("é").Delete(2).Retain(0).Insert("")
+	assert.Equal(t, Delta{
+		{Retain: 5},
+		{Insert: "aé"},
+		{Delete: 3},
+	}, d)
+}
+
+func TestDelta_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		rope    Rope
+		delta   Delta
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "empty",
+			rope:  FromString("hello"),
+			delta: nil,
+			want:  "hello",
+		},
+		{
+			name:  "insert",
+			rope:  FromString("héllo"),
+			delta: Delta{}.Retain(5).Insert(" wörld"),
+			want:  "héllo wörld",
+		},
+		{
+			name:  "delete",
+			rope:  newNode(FromString("héllo "), FromString("wörld")),
+			delta: Delta{}.Retain(1).Delete(6).Insert("i"),
+			want:  "hiörld",
+		},
+		{
+			name:    "retain out of range",
+			rope:    FromString("hello"),
+			delta:   Delta{}.Retain(6),
+			wantErr: ErrDeltaOutOfRange,
+		},
+		{
+			name:    "delete out of range",
+			rope:    FromString("hello"),
+			delta:   Delta{}.Retain(3).Delete(3),
+			wantErr: ErrDeltaOutOfRange,
+		},
+		{
+			name:    "invalid op",
+			rope:    FromString("hello"),
+			delta:   Delta{{Retain: 1, Delete: 1}},
+			wantErr: ErrInvalidOp,
+		},
+		{
+			name:    "empty op",
+			rope:    FromString("hello"),
+			delta:   Delta{{}},
+			wantErr: ErrInvalidOp,
+		},
+		{
+			name:    "negative op",
+			rope:    FromString("hello"),
+			delta:   Delta{{Retain: -1}},
+			wantErr: ErrInvalidOp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.delta.Apply(tt.rope)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestDelta_Invert(t *testing.T) {
+	base := FromString("héllo wörld")
+	d := Delta{}.Retain(1).Delete(4).Insert("i").Retain(1).Delete(5).Insert("😀")
+	applied, err := d.Apply(base)
+	require.NoError(t, err)
+	assert.Equal(t, "hi 😀", applied.String())
+
+	inverse := d.Invert(base)
+	assert.Equal(t, Delta{}.Retain(1).Insert("éllo").Delete(1).Retain(1).Insert("wörld").Delete(1), inverse)
+	restored, err := inverse.Apply(applied)
+	require.NoError(t, err)
+	assert.Equal(t, base.String(), restored.String())
+}
+
+func TestDelta_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		base := FromString(string([]rune("abcdéfghij€")[:rng.Intn(12)]))
+		a, b := randomDelta(rng, base.Length()), randomDelta(rng, base.Length())
+		name := fmt.Sprintf("%q a=%v b=%v", base.String(), a, b)
+
+		afterA, err := a.Apply(base)
+		require.NoError(t, err, name)
+		afterB, err := b.Apply(base)
+		require.NoError(t, err, name)
+
+		// invert
+		restored, err := a.Invert(base).Apply(afterA)
+		require.NoError(t, err, name)
+		require.Equal(t, base.String(), restored.String(), name)
+
+		// compose
+		c := randomDelta(rng, afterA.Length())
+		afterC, err := c.Apply(afterA)
+		require.NoError(t, err, name)
+		composed, err := Compose(a, c).Apply(base)
+		require.NoError(t, err, name)
+		require.Equal(t, afterC.String(), composed.String(), "%s c=%v", name, c)
+
+		// transform
+		aPrime, bPrime := Transform(a, b)
+		left, err := bPrime.Apply(afterA)
+		require.NoError(t, err, name)
+		right, err := aPrime.Apply(afterB)
+		require.NoError(t, err, name)
+		require.Equal(t, left.String(), right.String(), name)
+	}
+}
+
+func TestTransform_InsertPriority(t *testing.T) {
+	base := FromString("ac")
+	a := Delta{}.Retain(1).Insert("x")
+	b := Delta{}.Retain(1).Insert("y")
+	aPrime, bPrime := Transform(a, b)
+
+	afterA, err := a.Apply(base)
+	require.NoError(t, err)
+	left, err := bPrime.Apply(afterA)
+	require.NoError(t, err)
+	assert.Equal(t, "axyc", left.String())
+
+	afterB, err := b.Apply(base)
+	require.NoError(t, err)
+	right, err := aPrime.Apply(afterB)
+	require.NoError(t, err)
+	assert.Equal(t, "axyc", right.String())
+}
+
+func TestDelta_JSON(t *testing.T) {
+	d := Delta{}.Retain(5).Insert("wörld").Delete(2)
+
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"retain":5},{"insert":"wörld"},{"delete":2}]`, string(data))
+
+	var got Delta
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, d, got)
+}
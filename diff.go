@@ -0,0 +1,396 @@
+package rope
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRuneDiff limits the edit distance explored when refining a changed block of lines to individual runes.
+// Blocks which differ by more than this are replaced wholesale.
+const maxRuneDiff = 1024
+
+// maxLineDiff limits the edit distance explored when comparing lines, as the memory used by the Myers algorithm
+// grows with its square. Lines which differ by more than this are replaced wholesale as a single block.
+const maxLineDiff = 1024
+
+// Hunk describes a block of changed lines: OldLines lines starting at (zero-based) line OldLine of the old rope
+// are replaced by NewLines lines starting at line NewLine of the new rope.
+type Hunk struct {
+	OldLine  int
+	OldLines int
+	NewLine  int
+	NewLines int
+}
+
+// Diff compares two ropes, returning a Delta which transforms a into b along with the changed blocks of lines.
+// Subtrees shared between the ropes, as is common when one is derived from the other, are skipped without
+// comparing their runes. The remaining lines are compared using the Myers algorithm, and each changed block of
+// lines is then refined to individual runes so that the Delta is minimal.
+func Diff(a, b Rope) (Delta, []Hunk) {
+	prefix := commonPrefix(a, b, min(a.Length(), b.Length()))
+	suffix := commonSuffix(a, b, min(a.Length(), b.Length())-prefix)
+
+	// align the unchanged regions to line boundaries, so that only whole lines are compared
+	if prefix > 0 && a.At(prefix-1) == '\r' {
+		// the "\r" may be followed by a "\n" in only one of the ropes
+		prefix--
+	}
+	line, col := a.LineColumn(prefix)
+	prefix -= col
+	if s := a.Sub(a.Length()-suffix, a.Length()); s.NewLineCount() > 0 {
+		suffix -= s.OffsetOfLine(1)
+	} else {
+		suffix = 0
+	}
+
+	oldLines := splitLines(a.Sub(prefix, a.Length()-suffix))
+	newLines := splitLines(b.Sub(prefix, b.Length()-suffix))
+	ops, ok := myers(oldLines, newLines, maxLineDiff)
+	if !ok {
+		ops = replaceOps(len(oldLines), len(newLines))
+	}
+
+	delta := Delta{}.Retain(prefix)
+	var hunks []Hunk
+	var i, j int
+	for k := 0; k < len(ops); k++ {
+		if ops[k].kind == diffEqual {
+			for _, l := range oldLines[i : i+ops[k].n] {
+				delta = delta.Retain(utf8.RuneCountInString(l))
+			}
+			i += ops[k].n
+			j += ops[k].n
+			continue
+		}
+		// gather the deletions and insertions of the changed block
+		hunk := Hunk{
+			OldLine: line + i,
+			NewLine: line + j,
+		}
+		for ; k < len(ops) && ops[k].kind != diffEqual; k++ {
+			if ops[k].kind == diffDelete {
+				hunk.OldLines += ops[k].n
+			} else {
+				hunk.NewLines += ops[k].n
+			}
+		}
+		k--
+		hunks = append(hunks, hunk)
+		old := []rune(strings.Join(oldLines[i:i+hunk.OldLines], ""))
+		new := []rune(strings.Join(newLines[j:j+hunk.NewLines], ""))
+		delta = appendRuneDiff(delta, old, new)
+		i += hunk.OldLines
+		j += hunk.NewLines
+	}
+	return delta.chop(), hunks
+}
+
+// appendRuneDiff appends the operations transforming old into new to a delta.
+func appendRuneDiff(delta Delta, old, new []rune) Delta {
+	ops, ok := myers(old, new, maxRuneDiff)
+	if !ok {
+		return delta.Delete(len(old)).Insert(string(new))
+	}
+	var j int
+	for _, op := range ops {
+		switch op.kind {
+		case diffEqual:
+			delta = delta.Retain(op.n)
+			j += op.n
+		case diffDelete:
+			delta = delta.Delete(op.n)
+		case diffInsert:
+			delta = delta.Insert(string(new[j : j+op.n]))
+			j += op.n
+		}
+	}
+	return delta
+}
+
+// replaceOps returns the operations deleting n elements and inserting m elements.
+func replaceOps(n, m int) []diffOp {
+	var ops []diffOp
+	if n > 0 {
+		ops = append(ops, diffOp{kind: diffDelete, n: n})
+	}
+	if m > 0 {
+		ops = append(ops, diffOp{kind: diffInsert, n: m})
+	}
+	return ops
+}
+
+// splitLines splits a tree into lines, each including its trailing line break.
+func splitLines(r Rope) []string {
+	var lines []string
+	var line strings.Builder
+	var cr bool // whether the line ends with a "\r" at the end of a leaf, which may be followed by "\n"
+	for text := range textInRange(r, 0, r.Length()) {
+		if cr && text != "" {
+			cr = false
+			if text[0] == '\n' {
+				line.WriteByte('\n')
+				text = text[1:]
+			}
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		for text != "" {
+			i, size := nextBreak(text)
+			if i < 0 {
+				line.WriteString(text)
+				break
+			}
+			line.WriteString(text[:i+size])
+			text = text[i+size:]
+			if text == "" && size == 1 && line.String()[line.Len()-1] == '\r' {
+				cr = true
+				break
+			}
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+// diffOp is a run of n equal, deleted or inserted elements.
+type diffOp struct {
+	kind diffKind
+	n    int
+}
+
+// myers returns the shortest edit script transforming a into b, using the Myers O(ND) algorithm.
+// If maxD is not negative and the edit distance exceeds it, false is returned.
+func myers[T comparable](a, b []T, maxD int) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if maxD >= 0 && maxD < limit {
+		limit = maxD
+	}
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[-d..d] as it was before step d, for backtracking
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the trace of the Myers algorithm from the end, recovering the edit script.
+func backtrack(trace [][]int, x, y int) []diffOp {
+	var ops []diffOp
+	push := func(kind diffKind, n int) {
+		if n == 0 {
+			return
+		}
+		if len(ops) > 0 && ops[len(ops)-1].kind == kind {
+			ops[len(ops)-1].n += n
+			return
+		}
+		ops = append(ops, diffOp{kind: kind, n: n})
+	}
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		snake := min(x-prevX, y-prevY)
+		if d == 0 {
+			snake = x
+		}
+		push(diffEqual, snake)
+		x, y = x-snake, y-snake
+		if d > 0 {
+			if x == prevX {
+				push(diffInsert, 1)
+			} else {
+				push(diffDelete, 1)
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// commonPrefix returns the length of the common prefix of two trees, up to limit.
+func commonPrefix(a, b Rope, limit int) int {
+	return commonLength(newDiffCursor(a, false), newDiffCursor(b, false), limit)
+}
+
+// commonSuffix returns the length of the common suffix of two trees, up to limit.
+func commonSuffix(a, b Rope, limit int) int {
+	return commonLength(newDiffCursor(a, true), newDiffCursor(b, true), limit)
+}
+
+// commonLength walks two cursors in step, returning how many runes they have in common up to limit.
+// When both cursors reach the same subtree at the same position, it is skipped without reading its runes.
+func commonLength(a, b *diffCursor, limit int) int {
+	var offset int
+	for offset < limit {
+		switch {
+		case len(a.data) == 0 && len(b.data) == 0:
+			if len(a.stack) == 0 || len(b.stack) == 0 {
+				return offset
+			}
+			at, bt := a.top(), b.top()
+			if identical(at, bt) {
+				if offset+at.Length() > limit {
+					// the subtree crosses the limit, so descend into it
+					a.expand()
+					b.expand()
+					continue
+				}
+				a.pop()
+				b.pop()
+				offset += at.Length()
+				continue
+			}
+			// expand the larger subtree first, as it may contain the smaller one
+			if at.Length() >= bt.Length() {
+				a.expand()
+			} else {
+				b.expand()
+			}
+		case len(a.data) == 0:
+			if len(a.stack) == 0 {
+				return offset
+			}
+			a.expand()
+		case len(b.data) == 0:
+			if len(b.stack) == 0 {
+				return offset
+			}
+			b.expand()
+		default:
+			ac, an := a.peek()
+			bc, bn := b.peek()
+			if ac != bc {
+				return offset
+			}
+			offset++
+			a.consume(an)
+			b.consume(bn)
+		}
+	}
+	return limit
+}
+
+// diffCursor walks the leaves of a tree in either direction.
+type diffCursor struct {
+	stack   []Rope // subtrees still to be visited, with the next on top
+	data    string // the UTF-8 encoding of the unvisited runes of the current leaf
+	reverse bool
+}
+
+func newDiffCursor(r Rope, reverse bool) *diffCursor {
+	return &diffCursor{
+		stack:   []Rope{r},
+		reverse: reverse,
+	}
+}
+
+func (c *diffCursor) top() Rope {
+	return c.stack[len(c.stack)-1]
+}
+
+func (c *diffCursor) pop() Rope {
+	top := c.top()
+	c.stack = c.stack[:len(c.stack)-1]
+	return top
+}
+
+// expand replaces the subtree on top of the stack with its children, or reads it if it is a leaf.
+func (c *diffCursor) expand() {
+	top := c.pop()
+	count := top.childCount()
+	if count == 0 {
+		c.data = top.String()
+		return
+	}
+	for i := 0; i < count; i++ {
+		if c.reverse {
+			c.stack = append(c.stack, top.child(i))
+		} else {
+			c.stack = append(c.stack, top.child(count-1-i))
+		}
+	}
+}
+
+// peek returns the next unvisited rune in the direction of travel, and its size in bytes.
+func (c *diffCursor) peek() (rune, int) {
+	if c.reverse {
+		return utf8.DecodeLastRuneInString(c.data)
+	}
+	return utf8.DecodeRuneInString(c.data)
+}
+
+// consume skips n bytes in the direction of travel.
+func (c *diffCursor) consume(n int) {
+	if c.reverse {
+		c.data = c.data[:len(c.data)-n]
+	} else {
+		c.data = c.data[n:]
+	}
+}
+
+// identical reports whether two trees are the same subtree, rather than merely equal.
+func identical(a, b Rope) bool {
+	switch an := a.(type) {
+	case *LazyLeaf:
+		bl, ok := b.(*LazyLeaf)
+		return ok && an.text == bl.text
+	case *Leaf:
+		// leaves are small, so equal text is as good as the same leaf
+		bl, ok := b.(*Leaf)
+		return ok && (an == bl || an.length == bl.length && an.data == bl.data)
+	case *Node:
+		bn, ok := b.(*Node)
+		return ok && an == bn
+	case *BTreeNode:
+		bn, ok := b.(*BTreeNode)
+		return ok && an == bn
+	default:
+		return false
+	}
+}
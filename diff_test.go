@@ -0,0 +1,194 @@
+package rope
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      Rope
+		wantDelta Delta
+		wantHunks []Hunk
+	}{
+		{
+			name:      "equal",
+			a:         FromString("abc\ndef\n"),
+			b:         FromString("abc\ndef\n"),
+			wantDelta: Delta{},
+			wantHunks: nil,
+		},
+		{
+			name:      "insert line",
+			a:         FromString("abc\nghi\n"),
+			b:         FromString("abc\ndef\nghi\n"),
+			wantDelta: Delta{}.Retain(4).Insert("def\n"),
+			wantHunks: []Hunk{{OldLine: 1, OldLines: 0, NewLine: 1, NewLines: 1}},
+		},
+		{
+			name:      "delete line",
+			a:         FromString("abc\ndef\nghi\n"),
+			b:         FromString("abc\nghi\n"),
+			wantDelta: Delta{}.Retain(4).Delete(4),
+			wantHunks: []Hunk{{OldLine: 1, OldLines: 1, NewLine: 1, NewLines: 0}},
+		},
+		{
+			name:      "change within line",
+			a:         FromString("abc\nhéllo wörld\nghi"),
+			b:         FromString("abc\nhello wörld!\nghi"),
+			wantDelta: Delta{}.Retain(5).Insert("e").Delete(1).Retain(9).Insert("!"),
+			wantHunks: []Hunk{{OldLine: 1, OldLines: 1, NewLine: 1, NewLines: 1}},
+		},
+		{
+			name:      "separate hunks",
+			a:         FromString("a\nb\nc\nd\ne\n"),
+			b:         FromString("a\nB\nc\nd\nE\n"),
+			wantDelta: Delta{}.Retain(2).Insert("B").Delete(1).Retain(5).Insert("E").Delete(1),
+			wantHunks: []Hunk{
+				{OldLine: 1, OldLines: 1, NewLine: 1, NewLines: 1},
+				{OldLine: 4, OldLines: 1, NewLine: 4, NewLines: 1},
+			},
+		},
+		{
+			name:      "no trailing new line",
+			a:         FromString("abc\ndef"),
+			b:         FromString("abc\ndef\n"),
+			wantDelta: Delta{}.Retain(7).Insert("\n"),
+			wantHunks: []Hunk{{OldLine: 1, OldLines: 1, NewLine: 1, NewLines: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, hunks := Diff(tt.a, tt.b)
+			assert.Equal(t, tt.wantDelta, delta)
+			assert.Equal(t, tt.wantHunks, hunks)
+		})
+	}
+}
+
+func TestDiff_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	text := func() string {
+		var sb strings.Builder
+		for i := rng.Intn(20); i > 0; i-- {
+			sb.WriteString([]string{"a", "b", "é", "😀", "\n", "\n\n", "\r", "\r\n"}[rng.Intn(8)])
+		}
+		return sb.String()
+	}
+	for i := 0; i < 300; i++ {
+		a := testSearchRope(text() + text())
+		b := a
+		for j := rng.Intn(4); j >= 0; j-- {
+			start := rng.Intn(b.Length() + 1)
+			b = b.Delete(start, start+rng.Intn(4)).InsertString(start, text())
+		}
+		name := fmt.Sprintf("%q -> %q", a.String(), b.String())
+
+		delta, hunks := Diff(a, b)
+		got, err := delta.Apply(a)
+		require.NoError(t, err, name)
+		require.Equal(t, b.String(), got.String(), name)
+
+		// applying the hunks line by line also gives the new rope
+		oldLines, newLines := splitLines(a), splitLines(b)
+		for k, l := range oldLines {
+			require.Equal(t, a.Line(k).String(), strings.TrimRight(l, "\r\n"), name)
+		}
+		var patched []string
+		var line int
+		for _, h := range hunks {
+			patched = append(patched, oldLines[line:h.OldLine]...)
+			patched = append(patched, newLines[h.NewLine:h.NewLine+h.NewLines]...)
+			line = h.OldLine + h.OldLines
+		}
+		patched = append(patched, oldLines[line:]...)
+		require.Equal(t, b.String(), strings.Join(patched, ""), name)
+	}
+}
+
+func TestDiff_SharedSubtrees(t *testing.T) {
+	a, err := FromReader(strings.NewReader(strings.Repeat("hello world\n", 100_000)))
+	require.NoError(t, err)
+	b := a.InsertString(600_000, "goodbye\n")
+
+	assert.Equal(t, a.Length(), commonPrefix(a, a, a.Length()))
+
+	delta, hunks := Diff(a, b)
+	assert.Equal(t, Delta{}.Retain(600_000).Insert("goodbye\n"), delta)
+	assert.Equal(t, []Hunk{{OldLine: 50_000, OldLines: 0, NewLine: 50_000, NewLines: 1}}, hunks)
+}
+
+func TestDiff_Unrelated(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 4000; i++ {
+		fmt.Fprintf(&a, "old line %d\n", i)
+		fmt.Fprintf(&b, "new line %d\n", i)
+	}
+	ra, rb := FromString(a.String()), FromString(b.String())
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	delta, hunks := Diff(ra, rb)
+	runtime.ReadMemStats(&after)
+	// lines which differ too much are replaced as a single block, rather than compared in quadratic memory
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20))
+	assert.Equal(t, []Hunk{{OldLine: 0, OldLines: 4000, NewLine: 0, NewLines: 4000}}, hunks)
+	got, err := delta.Apply(ra)
+	require.NoError(t, err)
+	assert.Equal(t, rb.String(), got.String())
+}
+
+func Test_myers(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []diffOp
+	}{
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: nil,
+		},
+		{
+			name: "insert all",
+			a:    "",
+			b:    "abc",
+			want: []diffOp{{kind: diffInsert, n: 3}},
+		},
+		{
+			name: "delete all",
+			a:    "abc",
+			b:    "",
+			want: []diffOp{{kind: diffDelete, n: 3}},
+		},
+		{
+			name: "mixed",
+			a:    "abcabba",
+			b:    "cbabac",
+			want: []diffOp{
+				{kind: diffDelete, n: 2},
+				{kind: diffEqual, n: 1},
+				{kind: diffInsert, n: 1},
+				{kind: diffEqual, n: 2},
+				{kind: diffDelete, n: 1},
+				{kind: diffEqual, n: 1},
+				{kind: diffInsert, n: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := myers([]rune(tt.a), []rune(tt.b), -1)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package rope
+
+// Edit describes the replacement of the runes between the Start and End indexes with Text.
+// An insertion has an equal Start and End, and a deletion has empty Text.
+//
+// A list of edits, such as those returned by Replace and History.Undo or passed to History.Apply, is positioned
+// against the rope before any of them are applied: the edits are ordered by Start and do not overlap, so applying
+// them from last to first keeps the indexes of the others valid.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply applies the edit to a tree, returning the new tree.
+func (e Edit) Apply(r Rope) Rope {
+	return r.Delete(e.Start, e.End).InsertString(e.Start, e.Text)
+}
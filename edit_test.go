@@ -0,0 +1,40 @@
+package rope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdit_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		edit Edit
+		want string
+	}{
+		{
+			name: "insert",
+			rope: newNode(FromString("abc"), FromString("def")),
+			edit: Edit{Start: 3, End: 3, Text: "xyz"},
+			want: "abcxyzdef",
+		},
+		{
+			name: "delete",
+			rope: newNode(FromString("abc"), FromString("def")),
+			edit: Edit{Start: 2, End: 4, Text: ""},
+			want: "abef",
+		},
+		{
+			name: "replace",
+			rope: newNode(FromString("abc"), FromString("def")),
+			edit: Edit{Start: 1, End: 5, Text: "é€"},
+			want: "aé€f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.edit.Apply(tt.rope).String())
+		})
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/liamg/rope
 
-go 1.21.0
+go 1.23.0
 
 require github.com/stretchr/testify v1.8.4
 
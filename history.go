@@ -0,0 +1,275 @@
+package rope
+
+import (
+	"cmp"
+	"slices"
+)
+
+// History records the versions of a rope as an undo tree.
+// Each revision keeps the rope it produced along with the edits that produced it. Because ropes are immutable and
+// share unchanged leaves, keeping every version is cheap. Undoing and then making a new edit starts a new branch
+// rather than discarding the undone revisions, which remain reachable with RedoBranch.
+type History struct {
+	root    *revision
+	current *revision
+	working Rope // the current rope, including the edits of any open transaction
+	pending *revision
+	depth   int // number of open transactions
+	size    int // number of revisions in the tree
+	bytes   int // bytes of text in the edits of the revisions in the tree
+	opts    HistoryOptions
+}
+
+// HistoryOptions limits the revisions kept by a History. When either limit is exceeded, the oldest revisions are
+// dropped.
+type HistoryOptions struct {
+	// MaxRevisions is the number of revisions kept, including the current one. If it is zero or negative, any number
+	// of revisions is kept.
+	MaxRevisions int
+	// MaxBytes is the number of bytes of text kept by the edits of the revisions: the text each one inserted and the
+	// text it deleted. This is the text which a revision's rope does not share with the rope of its parent, so it
+	// approximates the memory the history keeps alive besides the current rope. If it is zero or negative, any
+	// amount of text is kept.
+	MaxBytes int
+}
+
+// revision is a node in the undo tree.
+type revision struct {
+	parent   *revision
+	children []*revision
+	redo     *revision // the child revisited by Redo
+	rope     Rope
+	edits    []Edit // transform the parent rope into this one
+	inverse  []Edit // transform this rope into the parent rope
+	bytes    int    // bytes of text in edits and inverse
+	delta    Delta  // the edits of a revision which is still being recorded
+}
+
+// NewHistory creates a history starting at the given rope, which keeps every revision.
+func NewHistory(r Rope) *History {
+	return NewHistoryWithOptions(r, HistoryOptions{})
+}
+
+// NewHistoryWithOptions creates a history starting at the given rope, which drops the oldest revisions when either
+// of the limits of the options is exceeded.
+func NewHistoryWithOptions(r Rope, opts HistoryOptions) *History {
+	root := &revision{
+		rope: r,
+	}
+	return &History{
+		root:    root,
+		current: root,
+		working: r,
+		size:    1,
+		opts:    opts,
+	}
+}
+
+// Current returns the current rope.
+func (h *History) Current() Rope {
+	return h.working
+}
+
+// Apply applies a list of edits to the current rope, recording them as a new revision, and returns the new rope.
+// As described by Edit, the edits are all positioned against the current rope. They are sorted by Start, and any
+// which overlap the edit before them are trimmed. If a transaction is open, the edits are added to it instead.
+func (h *History) Apply(edits ...Edit) Rope {
+	if len(edits) == 0 {
+		return h.working
+	}
+	edits = normaliseAll(h.working, edits)
+	var delta Delta
+	var pos int
+	for _, e := range edits {
+		delta = delta.Retain(e.Start - pos).Insert(e.Text).Delete(e.End - e.Start)
+		pos = e.End
+	}
+	rev := h.pending
+	if rev == nil {
+		rev = &revision{}
+	}
+	rev.delta = Compose(rev.delta, delta)
+	h.working = applyEdits(h.working, edits)
+	if h.depth > 0 {
+		h.pending = rev
+		return h.working
+	}
+	h.record(rev)
+	return h.working
+}
+
+// Begin opens a transaction, grouping all edits applied until the matching Commit into a single revision.
+// Transactions may be nested, in which case the outermost transaction is recorded.
+func (h *History) Begin() {
+	h.depth++
+}
+
+// Commit closes the transaction opened by the last call to Begin.
+func (h *History) Commit() {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth == 0 && h.pending != nil {
+		rev := h.pending
+		h.pending = nil
+		h.record(rev)
+	}
+}
+
+// Undo moves to the parent revision, returning the edits which transform the previous rope into the new current
+// rope. It returns false if there is nothing to undo. Any open transactions are committed first.
+func (h *History) Undo() ([]Edit, bool) {
+	h.commitAll()
+	if h.current.parent == nil {
+		return nil, false
+	}
+	rev := h.current
+	rev.parent.redo = rev
+	h.current = rev.parent
+	h.working = h.current.rope
+	return rev.inverse, true
+}
+
+// Redo moves to the most recently visited child revision, returning the edits which transform the previous rope
+// into the new current rope. It returns false if there is nothing to redo. Any open transactions are committed first.
+func (h *History) Redo() ([]Edit, bool) {
+	h.commitAll()
+	if h.current.redo == nil {
+		return nil, false
+	}
+	return h.moveTo(h.current.redo), true
+}
+
+// Branches returns the number of child revisions which can be redone from the current revision.
+func (h *History) Branches() int {
+	h.commitAll()
+	return len(h.current.children)
+}
+
+// RedoBranch moves to the child revision with the given index, where children are ordered from oldest to newest.
+// It returns false if there is no such child. Any open transactions are committed first.
+func (h *History) RedoBranch(i int) ([]Edit, bool) {
+	h.commitAll()
+	if i < 0 || i >= len(h.current.children) {
+		return nil, false
+	}
+	return h.moveTo(h.current.children[i]), true
+}
+
+// Len returns the number of revisions kept, including the current one.
+func (h *History) Len() int {
+	return h.size
+}
+
+func (h *History) moveTo(rev *revision) []Edit {
+	h.current.redo = rev
+	h.current = rev
+	h.working = rev.rope
+	return rev.edits
+}
+
+func (h *History) commitAll() {
+	for h.depth > 0 {
+		h.Commit()
+	}
+}
+
+// record adds a revision as the newest child of the current revision and moves to it.
+func (h *History) record(rev *revision) {
+	rev.parent = h.current
+	rev.rope = h.working
+	rev.edits = rev.delta.edits()
+	rev.inverse = rev.delta.Invert(h.current.rope).edits()
+	rev.delta = nil
+	h.current.children = append(h.current.children, rev)
+	h.current.redo = rev
+	h.current = rev
+	h.size++
+	for _, e := range rev.edits {
+		rev.bytes += len(e.Text)
+	}
+	for _, e := range rev.inverse {
+		rev.bytes += len(e.Text)
+	}
+	h.bytes += rev.bytes
+	h.prune()
+}
+
+// prune drops the oldest revisions until the limits are respected. Branches of the root which do not lead to the
+// current revision are dropped first, oldest first, and then the root itself is replaced by its remaining child.
+// Once a rope is no longer referenced by a revision, any leaves it does not share with a kept rope can be
+// garbage collected.
+func (h *History) prune() {
+	for h.exceeded() {
+		var path *revision
+		if h.current != h.root {
+			path = h.current
+			for path.parent != h.root {
+				path = path.parent
+			}
+		}
+		if i := slices.IndexFunc(h.root.children, func(r *revision) bool { return r != path }); i >= 0 {
+			dropped := h.root.children[i]
+			h.root.children = slices.Delete(h.root.children, i, i+1)
+			if h.root.redo == dropped {
+				h.root.redo = path
+			}
+			size, bytes := dropped.total()
+			h.size -= size
+			h.bytes -= bytes
+			continue
+		}
+		path.parent = nil
+		path.edits = nil
+		path.inverse = nil
+		h.root = path
+		h.size--
+		h.bytes -= path.bytes
+		path.bytes = 0
+	}
+}
+
+// exceeded reports whether the history keeps more revisions or text than its options allow. Once only the current
+// revision is left, it keeps no text, so dropping revisions always brings the history within its limits.
+func (h *History) exceeded() bool {
+	if h.opts.MaxRevisions > 0 && h.size > h.opts.MaxRevisions {
+		return true
+	}
+	return h.opts.MaxBytes > 0 && h.bytes > h.opts.MaxBytes
+}
+
+// total returns the number of revisions in the subtree rooted at the revision, and the bytes of text in their edits.
+func (r *revision) total() (int, int) {
+	size, bytes := 1, r.bytes
+	for _, child := range r.children {
+		s, b := child.total()
+		size += s
+		bytes += b
+	}
+	return size, bytes
+}
+
+// normaliseAll clamps the indexes of a list of edits to the tree they are applied to, sorting them by Start and
+// trimming any which overlap the edit before them.
+func normaliseAll(r Rope, edits []Edit) []Edit {
+	edits = slices.Clone(edits)
+	for i, e := range edits {
+		edits[i] = normalise(r, e)
+	}
+	slices.SortStableFunc(edits, func(a, b Edit) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	for i := 1; i < len(edits); i++ {
+		edits[i].Start = max(edits[i].Start, edits[i-1].End)
+		edits[i].End = max(edits[i].End, edits[i].Start)
+	}
+	return edits
+}
+
+// normalise clamps the indexes of an edit to the tree it is applied to.
+func normalise(r Rope, e Edit) Edit {
+	e.Start = min(max(e.Start, 0), r.Length())
+	e.End = min(max(e.End, e.Start), r.Length())
+	return e
+}
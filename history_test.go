@@ -0,0 +1,202 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_UndoRedo(t *testing.T) {
+	h := NewHistory(FromString("hello"))
+	h.Apply(Edit{Start: 5, End: 5, Text: " world"})
+	h.Apply(Edit{Start: 0, End: 1, Text: "J"})
+	h.Apply(Edit{Start: 10, End: 20, Text: "!"})
+	assert.Equal(t, "Jello worl!", h.Current().String())
+	assert.Equal(t, 4, h.Len())
+
+	want := []string{"Jello world", "hello world", "hello"}
+	for _, w := range want {
+		before := h.Current()
+		edits, ok := h.Undo()
+		require.True(t, ok)
+		assert.Equal(t, w, h.Current().String())
+		assert.Equal(t, w, applyEdits(before, edits).String())
+	}
+	_, ok := h.Undo()
+	assert.False(t, ok)
+
+	want = []string{"hello world", "Jello world", "Jello worl!"}
+	for _, w := range want {
+		before := h.Current()
+		edits, ok := h.Redo()
+		require.True(t, ok)
+		assert.Equal(t, w, h.Current().String())
+		assert.Equal(t, w, applyEdits(before, edits).String())
+	}
+	_, ok = h.Redo()
+	assert.False(t, ok)
+}
+
+func TestHistory_Transaction(t *testing.T) {
+	h := NewHistory(FromString("abc"))
+	h.Begin()
+	h.Apply(Edit{Start: 3, End: 3, Text: "d"})
+	h.Begin()
+	h.Apply(Edit{Start: 4, End: 4, Text: "e"}, Edit{Start: 0, End: 1, Text: ""})
+	h.Commit()
+	assert.Equal(t, "bcde", h.Current().String())
+	assert.Equal(t, 1, h.Len())
+	h.Commit()
+	assert.Equal(t, 2, h.Len())
+
+	edits, ok := h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "abc", h.Current().String())
+	assert.Equal(t, "abc", applyEdits(FromString("bcde"), edits).String())
+
+	edits, ok = h.Redo()
+	require.True(t, ok)
+	assert.Equal(t, "bcde", h.Current().String())
+	assert.Equal(t, "bcde", applyEdits(FromString("abc"), edits).String())
+}
+
+func TestHistory_ReplaceAll(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			r := FromStringWithOptions("aXaXa", b.options)
+			h := NewHistory(r)
+			want, edits := r.ReplaceAll("a", "bb")
+			assert.Equal(t, "bbXbbXbb", h.Apply(edits...).String())
+			assert.Equal(t, want.String(), h.Current().String())
+
+			undo, ok := h.Undo()
+			require.True(t, ok)
+			assert.Equal(t, "aXaXa", h.Current().String())
+			assert.Equal(t, "aXaXa", applyEdits(want, undo).String())
+
+			redo, ok := h.Redo()
+			require.True(t, ok)
+			assert.Equal(t, "bbXbbXbb", h.Current().String())
+			assert.Equal(t, "bbXbbXbb", applyEdits(r, redo).String())
+		})
+	}
+}
+
+func TestHistory_UnorderedEdits(t *testing.T) {
+	h := NewHistory(FromString("abcdef"))
+	h.Apply(Edit{Start: 4, End: 6, Text: "X"}, Edit{Start: 0, End: 1, Text: "YZ"}, Edit{Start: 3, End: 5, Text: ""})
+	assert.Equal(t, "YZbcX", h.Current().String())
+
+	undo, ok := h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "abcdef", applyEdits(FromString("YZbcX"), undo).String())
+}
+
+func TestHistory_UndoCommitsTransaction(t *testing.T) {
+	h := NewHistory(FromString("abc"))
+	h.Begin()
+	h.Apply(Edit{Start: 3, End: 3, Text: "d"})
+	_, ok := h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "abc", h.Current().String())
+	_, ok = h.Redo()
+	require.True(t, ok)
+	assert.Equal(t, "abcd", h.Current().String())
+}
+
+func TestHistory_Branches(t *testing.T) {
+	h := NewHistory(FromString("a"))
+	h.Apply(Edit{Start: 1, End: 1, Text: "b"})
+	_, ok := h.Undo()
+	require.True(t, ok)
+	h.Apply(Edit{Start: 1, End: 1, Text: "c"})
+	_, ok = h.Undo()
+	require.True(t, ok)
+
+	assert.Equal(t, 2, h.Branches())
+	_, ok = h.Redo()
+	require.True(t, ok)
+	assert.Equal(t, "ac", h.Current().String())
+
+	_, ok = h.Undo()
+	require.True(t, ok)
+	_, ok = h.RedoBranch(0)
+	require.True(t, ok)
+	assert.Equal(t, "ab", h.Current().String())
+
+	// redo follows the most recently visited branch
+	_, ok = h.Undo()
+	require.True(t, ok)
+	_, ok = h.Redo()
+	require.True(t, ok)
+	assert.Equal(t, "ab", h.Current().String())
+
+	_, ok = h.RedoBranch(2)
+	assert.False(t, ok)
+}
+
+func TestHistory_Limit(t *testing.T) {
+	h := NewHistoryWithOptions(FromString(""), HistoryOptions{MaxRevisions: 3})
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		h.Apply(Edit{Start: h.Current().Length(), End: h.Current().Length(), Text: s})
+	}
+	assert.Equal(t, 3, h.Len())
+
+	_, ok := h.Undo()
+	require.True(t, ok)
+	_, ok = h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "abc", h.Current().String())
+	_, ok = h.Undo()
+	assert.False(t, ok)
+}
+
+func TestHistory_LimitDropsBranches(t *testing.T) {
+	h := NewHistoryWithOptions(FromString(""), HistoryOptions{MaxRevisions: 3})
+	h.Apply(Edit{Start: 0, End: 0, Text: "a"})
+	_, ok := h.Undo()
+	require.True(t, ok)
+	h.Apply(Edit{Start: 0, End: 0, Text: "b"})
+	_, ok = h.Undo()
+	require.True(t, ok)
+	h.Apply(Edit{Start: 0, End: 0, Text: "c"})
+	assert.Equal(t, 3, h.Len())
+
+	// the oldest branches are dropped before any ancestors of the current revision
+	h.Apply(Edit{Start: 1, End: 1, Text: "d"})
+	assert.Equal(t, 3, h.Len())
+	_, ok = h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, "c", h.Current().String())
+	_, ok = h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, 1, h.Branches())
+	_, ok = h.Undo()
+	assert.False(t, ok)
+}
+
+func TestHistory_MaxBytes(t *testing.T) {
+	h := NewHistoryWithOptions(FromString("abc"), HistoryOptions{MaxBytes: 25})
+	for i := 0; i < 5; i++ {
+		h.Apply(Edit{Start: 0, End: 0, Text: "0123456789"})
+	}
+	// each revision keeps the 10 bytes it inserted
+	assert.Equal(t, 3, h.Len())
+	_, ok := h.Undo()
+	require.True(t, ok)
+	_, ok = h.Undo()
+	require.True(t, ok)
+	assert.Equal(t, strings.Repeat("0123456789", 3)+"abc", h.Current().String())
+	_, ok = h.Undo()
+	assert.False(t, ok)
+
+	// a revision which keeps more text than the limit allows cannot be undone
+	h.Apply(Edit{Start: 0, End: h.Current().Length(), Text: "x"})
+	assert.Equal(t, 1, h.Len())
+	assert.Equal(t, 0, h.Branches())
+	_, ok = h.Undo()
+	assert.False(t, ok)
+	assert.Equal(t, "x", h.Current().String())
+}
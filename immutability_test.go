@@ -0,0 +1,114 @@
+package rope
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// version is a rope along with the runes it is expected to contain.
+type version struct {
+	rope Rope
+	want []rune
+}
+
+// randomEdit derives a new version from an existing one using a randomly chosen operation.
+// The expected runes of the new version are calculated independently of the rope.
+func randomEdit(rng *rand.Rand, v version, text func() string) (version, string) {
+	length := len(v.want)
+	i, j := rng.Intn(length+1), rng.Intn(length+1)
+	if i > j {
+		i, j = j, i
+	}
+	s := text()
+	switch rng.Intn(9) {
+	case 0:
+		return version{
+			rope: v.rope.Append(FromString(s)),
+			want: slices.Concat(v.want, []rune(s)),
+		}, fmt.Sprintf("Append(%d runes)", len([]rune(s)))
+	case 1:
+		return version{
+			rope: v.rope.Prepend(FromString(s)),
+			want: slices.Concat([]rune(s), v.want),
+		}, fmt.Sprintf("Prepend(%d runes)", len([]rune(s)))
+	case 2:
+		return version{
+			rope: v.rope.InsertString(i, s),
+			want: slices.Concat(v.want[:i], []rune(s), v.want[i:]),
+		}, fmt.Sprintf("InsertString(%d, %d runes)", i, len([]rune(s)))
+	case 3:
+		return version{
+			rope: v.rope.Delete(i, j),
+			want: slices.Concat(v.want[:i], v.want[j:]),
+		}, fmt.Sprintf("Delete(%d, %d)", i, j)
+	case 4:
+		left, _ := v.rope.Split(i)
+		return version{
+			rope: left,
+			want: slices.Clone(v.want[:i]),
+		}, fmt.Sprintf("Split(%d) left", i)
+	case 5:
+		_, right := v.rope.Split(i)
+		return version{
+			rope: right,
+			want: slices.Clone(v.want[i:]),
+		}, fmt.Sprintf("Split(%d) right", i)
+	case 6:
+		return version{
+			rope: v.rope.Sub(i, j),
+			want: slices.Clone(v.want[i:j]),
+		}, fmt.Sprintf("Sub(%d, %d)", i, j)
+	case 7:
+		return version{
+			rope: v.rope.Balance(),
+			want: slices.Clone(v.want),
+		}, "Balance()"
+	default:
+		r, _ := v.rope.ReplaceAll("a😀a", s)
+		return version{
+			rope: r,
+			want: []rune(strings.ReplaceAll(string(v.want), "a😀a", s)),
+		}, fmt.Sprintf("ReplaceAll(\"a😀a\", %d runes)", len([]rune(s)))
+	}
+}
+
+func TestRope_Immutability(t *testing.T) {
+	for _, backend := range testBackends {
+		for seed := int64(0); seed < 20; seed++ {
+			t.Run(fmt.Sprintf("%s seed %d", backend.name, seed), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(seed))
+				text := func() string {
+					n := rng.Intn(maxLeafSize / 2)
+					if rng.Intn(10) == 0 {
+						n = rng.Intn(maxLeafSize * 3)
+					}
+					runes := make([]rune, n)
+					for i := range runes {
+						runes[i] = []rune("ab€😀\n")[rng.Intn(5)]
+					}
+					return string(runes)
+				}
+
+				versions := []version{{rope: FromStringWithOptions("", backend.options), want: nil}}
+				var ops []string
+				for i := 0; i < 500; i++ {
+					// derive from a random earlier version, so that versions branch and share leaves
+					v, op := randomEdit(rng, versions[rng.Intn(len(versions))], text)
+					ops = append(ops, op)
+					require.Equalf(t, string(v.want), v.rope.String(), "after %v", ops)
+					versions = append(versions, v)
+				}
+
+				for i, v := range versions {
+					require.Equalf(t, string(v.want), v.rope.String(), "version %d changed after %v", i, ops)
+					require.Equalf(t, len(v.want), v.rope.Length(), "version %d changed length", i)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package rope
+
+import "unicode/utf8"
+
+// joinable reports whether two texts can be concatenated within a leaf. They cannot if invalid bytes at the end of a
+// would be completed into a valid rune by continuation bytes at the start of b, as the runes of the leaf would then
+// differ from those of the texts. Valid UTF-8 is always joinable.
+func joinable[T string | []byte](a T, b string) bool {
+	if b == "" || utf8.RuneStart(b[0]) {
+		return true
+	}
+	// a rune is at most four bytes, so only the last three bytes of a can be completed by b
+	tail := string(a[max(len(a)-(utf8.UTFMax-1), 0):])
+	head := b[:min(len(b), utf8.UTFMax-1)]
+	return utf8.RuneCountInString(tail+head) == utf8.RuneCountInString(tail)+utf8.RuneCountInString(head)
+}
+
+// invalidRanges returns the rune indexes of the runs of invalid bytes in a tree, in order.
+func invalidRanges(r Rope) [][]int {
+	var ranges [][]int
+	var offset int
+	for _, leaf := range r.leaves() {
+		if l, ok := leaf.(*LazyLeaf); ok && !l.invalid {
+			// the leaf is known to be valid without reading it
+			offset += leaf.Length()
+			continue
+		}
+		text := leaf.String()
+		if utf8.ValidString(text) {
+			offset += leaf.Length()
+			continue
+		}
+		for i := 0; i < len(text); {
+			c, size := utf8.DecodeRuneInString(text[i:])
+			if c == utf8.RuneError && size == 1 {
+				if n := len(ranges); n > 0 && ranges[n-1][1] == offset {
+					ranges[n-1][1]++
+				} else {
+					ranges = append(ranges, []int{offset, offset + 1})
+				}
+			}
+			offset++
+			i += size
+		}
+	}
+	return ranges
+}
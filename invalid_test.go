@@ -0,0 +1,205 @@
+package rope
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromReaderWithOptions_Lossless(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]int
+	}{
+		{
+			name:  "valid",
+			input: "héllo, 世界 😀\n",
+			want:  nil,
+		},
+		{
+			name:  "latin-1 byte",
+			input: "caf\xe9 au lait",
+			want:  [][]int{{3, 4}},
+		},
+		{
+			name:  "truncated rune",
+			input: "a\xe2\x82b\xf0\x9f\x98",
+			want:  [][]int{{1, 3}, {4, 7}},
+		},
+		{
+			name:  "surrogate and overlong encodings",
+			input: "\xed\xa0\x80\xc0\xaf",
+			want:  [][]int{{0, 5}},
+		},
+		{
+			name:  "replacement character is valid",
+			input: "�\xff",
+			want:  [][]int{{1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := FromReaderWithOptions(iotest.OneByteReader(strings.NewReader(tt.input)),
+				Options{MaxLeafSize: 2, Lossless: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, r.String())
+			assert.Equal(t, len(tt.input), r.ByteLength())
+			assert.Equal(t, tt.want, r.InvalidRanges())
+			var buf bytes.Buffer
+			_, err = r.WriteTo(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, buf.String())
+
+			lossy, err := FromReader(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, string([]rune(tt.input)), lossy.String())
+			assert.Equal(t, r.Length(), lossy.Length())
+			assert.Nil(t, lossy.InvalidRanges())
+		})
+	}
+}
+
+func TestFromFileWithOptions_Lossless(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 100_000)
+	rng.Read(data)
+	path := filepath.Join(t.TempDir(), "random")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	r, err := FromFileWithOptions(path, Options{Lossless: true})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = NewReader(r).WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, data, buf.Bytes())
+	assert.Equal(t, []rune(string(data)), r.Data())
+}
+
+// TestRope_Lossless_Edits edits ropes holding invalid bytes, checking that no invalid bytes are joined into a valid
+// rune, so that the runes of every version are the runes of the texts it was made from.
+func TestRope_Lossless_Edits(t *testing.T) {
+	// tokens splits a text into the bytes of each of its runes
+	tokens := func(s string) []string {
+		var tokens []string
+		for len(s) > 0 {
+			_, size := utf8.DecodeRuneInString(s)
+			tokens = append(tokens, s[:size])
+			s = s[size:]
+		}
+		return tokens
+	}
+	for _, backend := range testBackends {
+		for seed := int64(0); seed < 10; seed++ {
+			t.Run(fmt.Sprintf("%s seed %d", backend.name, seed), func(t *testing.T) {
+				o := backend.options
+				o.Lossless = true
+				rng := rand.New(rand.NewSource(seed))
+				text := func() string {
+					b := make([]byte, rng.Intn(12))
+					for i := range b {
+						b[i] = "a\n\xe2\x82\xac\xf0\x9f\x98\x80\xff"[rng.Intn(10)]
+					}
+					return string(b)
+				}
+
+				r := FromStringWithOptions("", o)
+				var want []string
+				var ops []string
+				for i := 0; i < 300; i++ {
+					at, end := rng.Intn(len(want)+1), rng.Intn(len(want)+1)
+					if at > end {
+						at, end = end, at
+					}
+					s := text()
+					switch rng.Intn(5) {
+					case 0:
+						r = r.InsertString(at, s)
+						want = append(want[:at:at], append(tokens(s), want[at:]...)...)
+						ops = append(ops, fmt.Sprintf("InsertString(%d, %q)", at, s))
+					case 1:
+						r = r.Append(FromStringWithOptions(s, o))
+						want = append(want[:len(want):len(want)], tokens(s)...)
+						ops = append(ops, fmt.Sprintf("Append(%q)", s))
+					case 2:
+						r = r.Prepend(FromStringWithOptions(s, o))
+						want = append(tokens(s), want...)
+						ops = append(ops, fmt.Sprintf("Prepend(%q)", s))
+					case 3:
+						r = r.Delete(at, end)
+						want = append(want[:at:at], want[end:]...)
+						ops = append(ops, fmt.Sprintf("Delete(%d, %d)", at, end))
+					default:
+						r = r.Balance()
+						ops = append(ops, "Balance()")
+					}
+
+					msg := fmt.Sprintf("after %v", ops)
+					require.Equal(t, strings.Join(want, ""), r.String(), msg)
+					require.Equal(t, len(want), r.Length(), msg)
+					requireStructure(t, r, msg)
+					var invalid [][]int
+					runes := make([]rune, 0, len(want))
+					for j, token := range want {
+						c, _ := utf8.DecodeRuneInString(token)
+						require.Equal(t, c, r.At(j), "At(%d) %s", j, msg)
+						runes = append(runes, c)
+						if c == utf8.RuneError && len(token) == 1 {
+							if n := len(invalid); n > 0 && invalid[n-1][1] == j {
+								invalid[n-1][1]++
+							} else {
+								invalid = append(invalid, []int{j, j + 1})
+							}
+						}
+					}
+					require.Equal(t, invalid, r.InvalidRanges(), msg)
+					require.Equal(t, runes, r.Data(), msg)
+				}
+			})
+		}
+	}
+}
+
+func TestRope_Lossless_Data(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			o := backend.options
+			o.Lossless = true
+			// the invalid bytes at the end of the first leaf and the start of the second form a valid rune together
+			r := FromStringWithOptions("a\xe2\x82", o).Append(FromStringWithOptions("\xacb", o))
+			assert.Equal(t, 5, r.Length())
+			assert.Equal(t, []rune{'a', utf8.RuneError, utf8.RuneError, utf8.RuneError, 'b'}, r.Data())
+		})
+	}
+}
+
+func Test_joinable(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{name: "valid", a: "€", b: "😀", want: true},
+		{name: "empty", a: "\xe2", b: "", want: true},
+		{name: "completes a rune", a: "a\xe2", b: "\x82\xac", want: false},
+		{name: "completes a four byte rune", a: "\xf0\x9f\x98", b: "\x80b", want: false},
+		{name: "continuation after a valid rune", a: "€", b: "\x82", want: true},
+		{name: "invalid lead", a: "\xff", b: "\x82", want: true},
+		{name: "incomplete", a: "\xf0", b: "\x9f\x98", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, joinable(tt.a, tt.b))
+		})
+	}
+}
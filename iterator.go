@@ -0,0 +1,172 @@
+package rope
+
+import (
+	"iter"
+	"unicode/utf8"
+)
+
+// Iterator walks the runes of a rope in either direction from a cursor.
+// The cursor sits between runes: Next returns the rune after it and Prev returns the rune before it.
+// A stack of the nodes above the current leaf is kept so that moving between leaves is amortised O(1).
+type Iterator struct {
+	rope   Rope
+	stack  []frame
+	data   string // UTF-8 encoding of the current leaf
+	length int    // number of runes in the current leaf
+	start  int    // index of the first rune of the current leaf
+	pos    int    // cursor position within the current leaf
+	offset int    // byte offset of the cursor within data
+}
+
+// frame records which child of a node the iterator descended into.
+type frame struct {
+	node  Rope
+	child int
+}
+
+func newIterator(r Rope, i int) *Iterator {
+	it := &Iterator{
+		rope: r,
+	}
+	it.Seek(i)
+	return it
+}
+
+// Offset returns the index of the rune after the cursor.
+func (it *Iterator) Offset() int {
+	return it.start + it.pos
+}
+
+// Seek moves the cursor before the rune at the given index, descending from the root.
+func (it *Iterator) Seek(i int) {
+	if i < 0 {
+		i = 0
+	}
+	if i > it.rope.Length() {
+		i = it.rope.Length()
+	}
+	it.stack = it.stack[:0]
+	node, start := it.rope, 0
+	for count := node.childCount(); count > 0; count = node.childCount() {
+		for c := 0; c < count; c++ {
+			child := node.child(c)
+			length := child.Length()
+			if i < start+length || c == count-1 {
+				it.stack = append(it.stack, frame{node: node, child: c})
+				node = child
+				break
+			}
+			start += length
+		}
+	}
+	it.setLeaf(node)
+	it.start = start
+	it.pos = i - start
+	it.offset = byteOffset(it.data, it.length, it.pos)
+}
+
+// setLeaf makes a leaf the current one. The text of a lazy leaf is read without being kept, so that walking a rope
+// does not hold a second copy of its text.
+func (it *Iterator) setLeaf(leaf Rope) {
+	it.data = leafString(leaf)
+	it.length = leaf.Length()
+}
+
+// leaf returns the current leaf.
+func (it *Iterator) leaf() Rope {
+	if len(it.stack) == 0 {
+		return it.rope
+	}
+	top := it.stack[len(it.stack)-1]
+	return top.node.child(top.child)
+}
+
+// Next returns the rune after the cursor and moves the cursor past it.
+// It returns false if the cursor is at the end of the rope.
+func (it *Iterator) Next() (rune, bool) {
+	for it.pos == it.length {
+		if !it.nextLeaf() {
+			return 0, false
+		}
+	}
+	r, size := utf8.DecodeRuneInString(it.data[it.offset:])
+	it.pos++
+	it.offset += size
+	return r, true
+}
+
+// Prev returns the rune before the cursor and moves the cursor before it.
+// It returns false if the cursor is at the start of the rope.
+func (it *Iterator) Prev() (rune, bool) {
+	for it.pos == 0 {
+		if !it.prevLeaf() {
+			return 0, false
+		}
+	}
+	r, size := utf8.DecodeLastRuneInString(it.data[:it.offset])
+	it.pos--
+	it.offset -= size
+	return r, true
+}
+
+// nextLeaf moves the cursor to the start of the following leaf.
+func (it *Iterator) nextLeaf() bool {
+	depth := len(it.stack) - 1
+	for depth >= 0 && it.stack[depth].child+1 >= it.stack[depth].node.childCount() {
+		depth--
+	}
+	if depth < 0 {
+		return false
+	}
+	it.stack = it.stack[:depth+1]
+	top := &it.stack[depth]
+	top.child++
+	node := top.node.child(top.child)
+	for node.childCount() > 0 {
+		it.stack = append(it.stack, frame{node: node, child: 0})
+		node = node.child(0)
+	}
+	it.start += it.length
+	it.setLeaf(node)
+	it.pos = 0
+	it.offset = 0
+	return true
+}
+
+// prevLeaf moves the cursor to the end of the preceding leaf.
+func (it *Iterator) prevLeaf() bool {
+	depth := len(it.stack) - 1
+	for depth >= 0 && it.stack[depth].child == 0 {
+		depth--
+	}
+	if depth < 0 {
+		return false
+	}
+	it.stack = it.stack[:depth+1]
+	top := &it.stack[depth]
+	top.child--
+	node := top.node.child(top.child)
+	for count := node.childCount(); count > 0; count = node.childCount() {
+		it.stack = append(it.stack, frame{node: node, child: count - 1})
+		node = node.child(count - 1)
+	}
+	it.setLeaf(node)
+	it.start -= it.length
+	it.pos = it.length
+	it.offset = len(it.data)
+	return true
+}
+
+// runesFrom returns an iterator over the index and value of each rune in a tree, starting at the given index.
+func runesFrom(r Rope, i int) iter.Seq2[int, rune] {
+	return func(yield func(int, rune) bool) {
+		it := newIterator(r, i)
+		for {
+			offset := it.Offset()
+			c, ok := it.Next()
+			if !ok || !yield(offset, c) {
+				return
+			}
+		}
+	}
+}
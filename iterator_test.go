@@ -0,0 +1,210 @@
+package rope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testIteratorRope() Rope {
+	leaves := []Rope{
+		FromString("ab"),
+		FromString(""),
+		FromString("cé"),
+		FromString("€😀"),
+		newNode(FromString("f"), FromString("")),
+		FromString("g"),
+	}
+	return defaultOptions.merge(leaves, 0, len(leaves))
+}
+
+func TestIterator_Next(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		at   int
+		want string
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			at:   0,
+			want: "",
+		},
+		{
+			name: "leaf",
+			rope: FromString("abc"),
+			at:   1,
+			want: "bc",
+		},
+		{
+			name: "start",
+			rope: testIteratorRope(),
+			at:   0,
+			want: "abcé€😀fg",
+		},
+		{
+			name: "leaf boundary",
+			rope: testIteratorRope(),
+			at:   4,
+			want: "€😀fg",
+		},
+		{
+			name: "middle of leaf",
+			rope: testIteratorRope(),
+			at:   5,
+			want: "😀fg",
+		},
+		{
+			name: "end",
+			rope: testIteratorRope(),
+			at:   8,
+			want: "",
+		},
+		{
+			name: "before start",
+			rope: testIteratorRope(),
+			at:   -1,
+			want: "abcé€😀fg",
+		},
+		{
+			name: "after end",
+			rope: testIteratorRope(),
+			at:   9,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := tt.rope.IterAt(tt.at)
+			var got []rune
+			for {
+				r, ok := it.Next()
+				if !ok {
+					break
+				}
+				got = append(got, r)
+			}
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.rope.Length(), it.Offset())
+		})
+	}
+}
+
+func TestIterator_Prev(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		at   int
+		want string
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			at:   0,
+			want: "",
+		},
+		{
+			name: "leaf",
+			rope: FromString("abc"),
+			at:   2,
+			want: "ba",
+		},
+		{
+			name: "end",
+			rope: testIteratorRope(),
+			at:   8,
+			want: "gf😀€écba",
+		},
+		{
+			name: "leaf boundary",
+			rope: testIteratorRope(),
+			at:   4,
+			want: "écba",
+		},
+		{
+			name: "middle of leaf",
+			rope: testIteratorRope(),
+			at:   5,
+			want: "€écba",
+		},
+		{
+			name: "start",
+			rope: testIteratorRope(),
+			at:   0,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := tt.rope.IterAt(tt.at)
+			var got []rune
+			for {
+				r, ok := it.Prev()
+				if !ok {
+					break
+				}
+				got = append(got, r)
+			}
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, 0, it.Offset())
+		})
+	}
+}
+
+func TestIterator_Seek(t *testing.T) {
+	r := testIteratorRope()
+	it := r.IterAt(0)
+	for i, want := range []rune("abcé€😀fg") {
+		it.Seek(i)
+		assert.Equal(t, i, it.Offset())
+		got, ok := it.Next()
+		assert.True(t, ok)
+		assert.Equalf(t, want, got, "Seek(%d)", i)
+	}
+}
+
+func TestIterator_Bidirectional(t *testing.T) {
+	it := testIteratorRope().IterAt(3)
+
+	r, _ := it.Next()
+	assert.Equal(t, 'é', r)
+	r, _ = it.Next()
+	assert.Equal(t, '€', r)
+	r, _ = it.Prev()
+	assert.Equal(t, '€', r)
+	r, _ = it.Prev()
+	assert.Equal(t, 'é', r)
+	r, _ = it.Prev()
+	assert.Equal(t, 'c', r)
+	r, _ = it.Prev()
+	assert.Equal(t, 'b', r)
+	r, _ = it.Next()
+	assert.Equal(t, 'b', r)
+	assert.Equal(t, 2, it.Offset())
+}
+
+func TestRope_Runes(t *testing.T) {
+	var indexes []int
+	var runes []rune
+	for i, r := range testIteratorRope().Runes() {
+		indexes = append(indexes, i)
+		runes = append(runes, r)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, indexes)
+	assert.Equal(t, "abcé€😀fg", string(runes))
+}
+
+func TestRope_RunesFrom(t *testing.T) {
+	var indexes []int
+	var runes []rune
+	for i, r := range testIteratorRope().RunesFrom(3) {
+		if r == 'f' {
+			break
+		}
+		indexes = append(indexes, i)
+		runes = append(runes, r)
+	}
+	assert.Equal(t, []int{3, 4, 5}, indexes)
+	assert.Equal(t, "é€😀", string(runes))
+}
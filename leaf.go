@@ -1,105 +1,176 @@
 package rope
 
-import "strings"
+import (
+	"io"
+	"iter"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
 
 var _ Rope = (*Leaf)(nil)
 
 const maxLeafSize = 256
 
+// Leaf holds the UTF-8 encoding of its runes, along with counts of them so that rune indexes can be converted to
+// byte offsets without decoding other leaves.
 type Leaf struct {
-	data []rune
+	data string
+	// the counts of the data, so that nodes can be built without scanning it
+	length      int
+	lineBreaks  breakCounts
+	utf16Length int
+	opts        *Options
 }
 
-func newLeaf(data []rune) Rope {
-	return &Leaf{
-		data: data,
+func (l Leaf) Options() Options {
+	return *l.options()
+}
+
+// options returns the options of the leaf, which are the defaults for the zero value.
+func (l Leaf) options() *Options {
+	if l.opts == nil {
+		return defaultOptions
 	}
+	return l.opts
 }
 
 func (l Leaf) String() string {
-	return string(l.data)
+	return l.data
 }
 
 func (l Leaf) Length() int {
-	return len(l.data)
+	return l.length
+}
+
+// singleByte reports whether every rune of the leaf is a single byte, being ASCII or an invalid byte, so that rune
+// indexes are byte offsets.
+func (l Leaf) singleByte() bool {
+	return l.length == len(l.data)
+}
+
+// byteOffset returns the offset in the data of the rune at the given index, which is clamped to the leaf.
+func (l Leaf) byteOffset(i int) int {
+	return byteOffset(l.data, l.length, i)
+}
+
+// byteOffset returns the offset in a text of the given number of runes of the rune at the given index, which is
+// clamped to the text.
+func byteOffset(s string, length, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= length {
+		return len(s)
+	}
+	if length == len(s) {
+		return i
+	}
+	for offset := range s {
+		if i == 0 {
+			return offset
+		}
+		i--
+	}
+	return len(s)
+}
+
+// runeIndex returns the index of the rune which starts at the given offset in the data.
+func (l Leaf) runeIndex(offset int) int {
+	if l.singleByte() {
+		return offset
+	}
+	return utf8.RuneCountInString(l.data[:offset])
 }
 
 func (l Leaf) Append(n Rope) Rope {
-	if l.Length()+n.Length() <= maxLeafSize {
-		return newLeaf(append(l.data, n.Data()...))
+	o := l.options()
+	n = o.adopt(n)
+	if l.Length()+n.Length() <= o.MaxLeafSize {
+		var sb strings.Builder
+		sb.Grow(len(l.data) + n.ByteLength())
+		sb.WriteString(l.data)
+		if appendLeafText(&sb, n) {
+			return o.newLeaf(sb.String())
+		}
 	}
-	return newNode(l, n)
+	return o.autoBalance(o.join(&l, n))
 }
 
 func (l Leaf) Prepend(n Rope) Rope {
-	if l.Length()+n.Length() <= maxLeafSize {
-		return newLeaf(append(n.Data(), l.data...))
+	o := l.options()
+	n = o.adopt(n)
+	if l.Length()+n.Length() <= o.MaxLeafSize {
+		var sb strings.Builder
+		sb.Grow(len(l.data) + n.ByteLength())
+		if appendLeafText(&sb, n) && joinable(sb.String(), l.data) {
+			sb.WriteString(l.data)
+			return o.newLeaf(sb.String())
+		}
 	}
-	return newNode(n, l)
+	return o.autoBalance(o.join(n, &l))
 }
 
 func (l Leaf) Split(at int) (Rope, Rope) {
-	if at < 0 {
-		at = 0
-	}
-	if at > len(l.data) {
-		at = len(l.data)
-	}
-	return newLeaf(l.data[:at]), newLeaf(l.data[at:])
+	b := l.byteOffset(at)
+	o := l.options()
+	return o.newLeaf(l.data[:b]), o.newLeaf(l.data[b:])
 }
 
 func (l Leaf) Sub(start, end int) Rope {
-	if start < 0 {
-		start = 0
-	}
-	if start > len(l.data) {
-		start = len(l.data)
-	}
-	if end < 0 {
-		end = 0
-	}
-	if end > len(l.data) {
-		end = len(l.data)
-	}
-	return newLeaf(l.data[start:end])
+	return l.options().newLeaf(l.data[l.byteOffset(start):l.byteOffset(end)])
 }
 
 func (l Leaf) Index(r rune) int {
-	return strings.IndexRune(l.String(), r)
+	if i := strings.IndexRune(l.data, r); i >= 0 {
+		return l.runeIndex(i)
+	}
+	return -1
 }
 
 func (l Leaf) LastIndex(r rune) int {
-	return strings.LastIndex(l.String(), string(r))
+	if r == utf8.RuneError {
+		// match invalid bytes as well, as Index does
+		for b := len(l.data); b > 0; {
+			c, size := utf8.DecodeLastRuneInString(l.data[:b])
+			b -= size
+			if c == utf8.RuneError {
+				return l.runeIndex(b)
+			}
+		}
+		return -1
+	}
+	if !utf8.ValidRune(r) {
+		return -1
+	}
+	if i := strings.LastIndex(l.data, string(r)); i >= 0 {
+		return l.runeIndex(i)
+	}
+	return -1
 }
 
 func (l Leaf) At(i int) rune {
-	if i < 0 || i >= len(l.data) {
+	if i < 0 || i >= l.length {
 		return -1
 	}
-	return l.data[i]
+	b := l.byteOffset(i)
+	if c := l.data[b]; c < utf8.RuneSelf {
+		return rune(c)
+	}
+	r, _ := utf8.DecodeRuneInString(l.data[b:])
+	return r
 }
 
 func (l Leaf) Line(line int) Rope {
 	if line < 0 || line > l.NewLineCount() {
-		return newLeaf(nil)
-	}
-	vl := 0
-	var start, end int
-	for i, r := range l.data {
-		if r == '\n' {
-			vl++
-			if vl == line {
-				start = i + 1
-			} else if vl == line+1 {
-				end = i
-				return newLeaf(l.data[start:end])
-			}
-		}
+		return l.options().newLeaf("")
 	}
-	if start == 0 && line == 1 {
-		return &l
+	start := l.lineOffset(line)
+	end, _ := nextBreak(l.data[start:])
+	if end < 0 {
+		return l.options().newLeaf(l.data[start:])
 	}
-	return newLeaf(l.data[start:])
+	return l.options().newLeaf(l.data[start : start+end])
 }
 
 func (l Leaf) Balance() Rope {
@@ -107,11 +178,28 @@ func (l Leaf) Balance() Rope {
 }
 
 func (l Leaf) NewLineCount() int {
-	if len(l.data) == 0 {
-		return 0
+	return l.lineBreaks.count()
+}
+
+func (l Leaf) breaks() lineBreaks {
+	if l.data == "" {
+		return lineBreaks{}
+	}
+	return lineBreaks{
+		breakCounts: l.lineBreaks,
+		firstLF:     l.data[0] == '\n',
+		lastCR:      l.data[len(l.data)-1] == '\r',
 	}
-	return strings.Count(l.String(), "\n")
+}
 
+// lineOffset returns the byte offset of the start of a line, which must exist.
+func (l Leaf) lineOffset(line int) int {
+	var start int
+	for ; line > 0; line-- {
+		i, size := nextBreak(l.data[start:])
+		start += i + size
+	}
+	return start
 }
 
 func (l Leaf) Depth() int {
@@ -122,6 +210,239 @@ func (l Leaf) leaves() []Rope {
 	return []Rope{l}
 }
 
+func (l Leaf) childCount() int {
+	return 0
+}
+
+func (l Leaf) child(int) Rope {
+	return nil
+}
+
 func (l Leaf) Data() []rune {
-	return l.data
+	return []rune(l.data)
+}
+
+func (l Leaf) Insert(at int, r Rope) Rope {
+	if r.Length() == 0 {
+		return &l
+	}
+	o := l.options()
+	r = o.adopt(r)
+	if l.length+r.Length() <= o.MaxLeafSize {
+		b := l.byteOffset(at)
+		var sb strings.Builder
+		sb.Grow(len(l.data) + r.ByteLength())
+		sb.WriteString(l.data[:b])
+		if appendLeafText(&sb, r) && joinable(sb.String(), l.data[b:]) {
+			sb.WriteString(l.data[b:])
+			return o.newLeaf(sb.String())
+		}
+	}
+	left, right := l.Split(at)
+	return o.autoBalance(o.join(o.join(left, r), right))
+}
+
+func (l Leaf) InsertString(at int, s string) Rope {
+	return l.Insert(at, l.options().fromString(s))
+}
+
+func (l Leaf) Delete(start, end int) Rope {
+	if start < 0 {
+		start = 0
+	}
+	if end > l.length {
+		end = l.length
+	}
+	if start >= end {
+		return &l
+	}
+	o := l.options()
+	left, right := l.data[:l.byteOffset(start)], l.data[l.byteOffset(end):]
+	if joinable(left, right) {
+		return o.newLeaf(left + right)
+	}
+	return o.join(o.newLeaf(left), o.newLeaf(right))
+}
+
+func (l Leaf) ByteLength() int {
+	return len(l.data)
+}
+
+func (l Leaf) RuneToByte(i int) int {
+	return l.byteOffset(i)
+}
+
+func (l Leaf) ByteToRune(b int) int {
+	if b < 0 {
+		return 0
+	}
+	if b >= len(l.data) {
+		return l.length
+	}
+	if l.singleByte() {
+		return b
+	}
+	// count the runes which start at or before the byte
+	var i int
+	for offset := range l.data {
+		if offset > b {
+			break
+		}
+		i++
+	}
+	return i - 1
+}
+
+func (l Leaf) SplitAtByte(b int) (Rope, Rope) {
+	return l.Split(l.ByteToRune(b))
+}
+
+func (l Leaf) UTF16Length() int {
+	return l.utf16Length
+}
+
+func (l Leaf) RuneToUTF16(i int) int {
+	i = min(max(i, 0), l.length)
+	if l.utf16Length == l.length {
+		// every rune is a single code unit
+		return i
+	}
+	var offset int
+	for _, r := range l.data[:l.byteOffset(i)] {
+		offset += utf16Len(r)
+	}
+	return offset
+}
+
+func (l Leaf) UTF16ToRune(u int) int {
+	if l.utf16Length == l.length {
+		return min(max(u, 0), l.length)
+	}
+	var offset, i int
+	for _, r := range l.data {
+		offset += utf16Len(r)
+		if offset > u {
+			return i
+		}
+		i++
+	}
+	return l.length
+}
+
+func (l Leaf) IterAt(i int) *Iterator {
+	return newIterator(l, i)
+}
+
+func (l Leaf) Runes() iter.Seq2[int, rune] {
+	return runesFrom(l, 0)
+}
+
+func (l Leaf) RunesFrom(i int) iter.Seq2[int, rune] {
+	return runesFrom(l, i)
+}
+
+func (l Leaf) OffsetOfLine(line int) int {
+	if line == 0 {
+		return 0
+	}
+	if line < 0 {
+		return -1
+	}
+	if line > l.NewLineCount() {
+		return -1
+	}
+	return l.runeIndex(l.lineOffset(line))
+}
+
+func (l Leaf) LineColumn(i int) (int, int) {
+	i = min(max(i, 0), l.length)
+	b := l.byteOffset(i)
+	if b > 0 && b < len(l.data) && l.data[b-1] == '\r' && l.data[b] == '\n' {
+		// the "\n" of a "\r\n" is on the same line as the "\r"
+		line, col := l.LineColumn(i - 1)
+		return line, col + 1
+	}
+	before := l.data[:b]
+	return countBreaks(before).count(), i - l.runeIndex(lineStart(before))
+}
+
+func (l Leaf) Offset(line, col int) int {
+	return offset(l, line, col)
+}
+
+// utf16Len returns the number of code units in the UTF-16 encoding of the rune.
+// Invalid runes are encoded as utf8.RuneError, which is a single code unit.
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+func (l Leaf) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(l, w)
+}
+
+func (l Leaf) Lines(from, to int) *LineIterator {
+	return newLineIterator(l, from, to)
+}
+
+func (l Leaf) Chunks() iter.Seq[string] {
+	return textInRange(l, 0, l.Length())
+}
+
+func (l Leaf) ChunksInRange(start, end int) iter.Seq[string] {
+	return textInRange(l, start, end)
+}
+
+func (l Leaf) IndexString(s string) int {
+	return indexFrom(l, s, 0)
+}
+
+func (l Leaf) IndexFrom(s string, from int) int {
+	return indexFrom(l, s, from)
+}
+
+func (l Leaf) LastIndexString(s string) int {
+	return lastIndex(l, s)
+}
+
+func (l Leaf) Count(s string) int {
+	return count(l, s)
+}
+
+func (l Leaf) FindRegexp(re *regexp.Regexp, from int) []int {
+	return findRegexp(l, re, from)
+}
+
+func (l Leaf) FindAllRegexp(re *regexp.Regexp, limit int) [][]int {
+	return findAllRegexp(l, re, limit)
+}
+
+func (l Leaf) InvalidRanges() [][]int {
+	return invalidRanges(l)
+}
+
+func (l Leaf) LineEnding() LineEnding {
+	return lineEnding(l)
+}
+
+func (l Leaf) LineEndings() LineEndingReport {
+	return lineEndings(l)
+}
+
+func (l Leaf) NormalizeLineEndings(e LineEnding) Rope {
+	return normalizeLineEndings(l, e)
+}
+
+func (l Leaf) Replace(old, new string, limit int) (Rope, []Edit) {
+	return replace(l, old, new, limit)
+}
+
+func (l Leaf) ReplaceAll(old, new string) (Rope, []Edit) {
+	return replace(l, old, new, -1)
+}
+
+func (l Leaf) ReplaceRegexp(re *regexp.Regexp, template string) (Rope, []Edit) {
+	return replaceRegexp(l, re, template)
 }
@@ -1,12 +1,19 @@
 package rope
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// newLeaf creates a leaf holding the given runes with the default options.
+func newLeaf(data []rune) Rope {
+	return defaultOptions.newLeaf(string(data))
+}
+
 func TestLeaf_Append(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -34,7 +41,7 @@ func TestLeaf_Append(t *testing.T) {
 			start:     []rune(strings.Repeat("a", maxLeafSize)),
 			appends:   []string{"b", "c", "d"},
 			want:      strings.Repeat("a", maxLeafSize) + "bcd",
-			wantDepth: 4,
+			wantDepth: 2,
 		},
 	}
 	for _, tt := range tests {
@@ -101,6 +108,158 @@ func TestLeaf_Balance(t *testing.T) {
 	assert.Equal(t, *(l.(*Leaf)), balanced)
 }
 
+func TestLeaf_ByteLength(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: 0,
+		},
+		{
+			name: "ascii",
+			data: []rune("abc"),
+			want: 3,
+		},
+		{
+			name: "multi-byte",
+			data: []rune("aé€😀"),
+			want: 10,
+		},
+		{
+			name: "invalid",
+			data: []rune{0xd800},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeaf(tt.data)
+			assert.Equalf(t, tt.want, l.ByteLength(), "ByteLength()")
+		})
+	}
+}
+
+func TestLeaf_ByteToRune(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			data: []rune("aé€"),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "first",
+			data: []rune("aé€"),
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "start of rune",
+			data: []rune("aé€"),
+			at:   3,
+			want: 2,
+		},
+		{
+			name: "inside rune",
+			data: []rune("aé€"),
+			at:   4,
+			want: 2,
+		},
+		{
+			name: "end",
+			data: []rune("aé€"),
+			at:   6,
+			want: 3,
+		},
+		{
+			name: "after end",
+			data: []rune("aé€"),
+			at:   10,
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeaf(tt.data)
+			assert.Equalf(t, tt.want, l.ByteToRune(tt.at), "ByteToRune(%v)", tt.at)
+		})
+	}
+}
+
+func TestLeaf_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []rune
+		start, end int
+		want       string
+	}{
+		{
+			name:  "empty",
+			data:  nil,
+			start: 0,
+			end:   1,
+			want:  "",
+		},
+		{
+			name:  "start",
+			data:  []rune("abcdef"),
+			start: 0,
+			end:   2,
+			want:  "cdef",
+		},
+		{
+			name:  "middle",
+			data:  []rune("abcdef"),
+			start: 2,
+			end:   4,
+			want:  "abef",
+		},
+		{
+			name:  "end",
+			data:  []rune("abcdef"),
+			start: 4,
+			end:   6,
+			want:  "abcd",
+		},
+		{
+			name:  "out of range",
+			data:  []rune("abcdef"),
+			start: -1,
+			end:   10,
+			want:  "",
+		},
+		{
+			name:  "end < start",
+			data:  []rune("abcdef"),
+			start: 4,
+			end:   2,
+			want:  "abcdef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeaf(tt.data)
+			assert.Equalf(t, tt.want, l.Delete(tt.start, tt.end).String(), "Delete(%v, %v)", tt.start, tt.end)
+			assert.Equal(t, string(tt.data), l.String())
+		})
+	}
+}
+
 func TestLeaf_Depth(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -118,9 +277,9 @@ func TestLeaf_Depth(t *testing.T) {
 			want:    2,
 		},
 		{
-			name:    "3 deep",
+			name:    "rebalanced",
 			appends: 1000,
-			want:    1001,
+			want:    18,
 		},
 	}
 	for _, tt := range tests {
@@ -177,6 +336,12 @@ func TestLeaf_Index(t *testing.T) {
 			r:    'b',
 			want: 1,
 		},
+		{
+			name: "multi-byte",
+			data: []rune("é€😀bb"),
+			r:    'b',
+			want: 3,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -186,6 +351,91 @@ func TestLeaf_Index(t *testing.T) {
 	}
 }
 
+func TestLeaf_Insert(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []rune
+		at        int
+		insert    string
+		want      string
+		wantDepth int
+	}{
+		{
+			name:      "into empty",
+			data:      nil,
+			at:        0,
+			insert:    "abc",
+			want:      "abc",
+			wantDepth: 1,
+		},
+		{
+			name:      "start",
+			data:      []rune("def"),
+			at:        0,
+			insert:    "abc",
+			want:      "abcdef",
+			wantDepth: 1,
+		},
+		{
+			name:      "middle",
+			data:      []rune("adef"),
+			at:        1,
+			insert:    "bc",
+			want:      "abcdef",
+			wantDepth: 1,
+		},
+		{
+			name:      "end",
+			data:      []rune("abc"),
+			at:        3,
+			insert:    "def",
+			want:      "abcdef",
+			wantDepth: 1,
+		},
+		{
+			name:      "before start",
+			data:      []rune("def"),
+			at:        -1,
+			insert:    "abc",
+			want:      "abcdef",
+			wantDepth: 1,
+		},
+		{
+			name:      "after end",
+			data:      []rune("abc"),
+			at:        4,
+			insert:    "def",
+			want:      "abcdef",
+			wantDepth: 1,
+		},
+		{
+			name:      "nothing",
+			data:      []rune("abc"),
+			at:        1,
+			insert:    "",
+			want:      "abc",
+			wantDepth: 1,
+		},
+		{
+			name:      "oversize",
+			data:      []rune(strings.Repeat("a", maxLeafSize)),
+			at:        maxLeafSize / 2,
+			insert:    "b",
+			want:      strings.Repeat("a", maxLeafSize/2) + "b" + strings.Repeat("a", maxLeafSize/2),
+			wantDepth: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeaf(tt.data)
+			got := l.InsertString(tt.at, tt.insert)
+			assert.Equalf(t, tt.want, got.String(), "InsertString(%v, %q)", tt.at, tt.insert)
+			assert.Equalf(t, tt.wantDepth, got.Depth(), "Depth() after insert")
+			assert.Equal(t, string(tt.data), l.String())
+		})
+	}
+}
+
 func TestLeaf_LastIndex(t *testing.T) {
 	tests := []struct {
 		name string
@@ -229,6 +479,12 @@ func TestLeaf_LastIndex(t *testing.T) {
 			r:    'b',
 			want: 2,
 		},
+		{
+			name: "multi-byte",
+			data: []rune("é€😀bb"),
+			r:    'b',
+			want: 4,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -331,6 +587,73 @@ func TestLeaf_Line(t *testing.T) {
 	}
 }
 
+func TestLeaf_LineColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []rune
+		at       int
+		wantLine int
+		wantCol  int
+	}{
+		{
+			name:     "empty",
+			data:     nil,
+			at:       0,
+			wantLine: 0,
+			wantCol:  0,
+		},
+		{
+			name:     "negative",
+			data:     []rune("abc\ndef"),
+			at:       -1,
+			wantLine: 0,
+			wantCol:  0,
+		},
+		{
+			name:     "first line",
+			data:     []rune("abc\ndef"),
+			at:       2,
+			wantLine: 0,
+			wantCol:  2,
+		},
+		{
+			name:     "new line",
+			data:     []rune("abc\ndef"),
+			at:       3,
+			wantLine: 0,
+			wantCol:  3,
+		},
+		{
+			name:     "start of second line",
+			data:     []rune("abc\ndef"),
+			at:       4,
+			wantLine: 1,
+			wantCol:  0,
+		},
+		{
+			name:     "end",
+			data:     []rune("abc\ndef"),
+			at:       7,
+			wantLine: 1,
+			wantCol:  3,
+		},
+		{
+			name:     "after end",
+			data:     []rune("abc\ndef\n"),
+			at:       10,
+			wantLine: 2,
+			wantCol:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col := newLeaf(tt.data).LineColumn(tt.at)
+			assert.Equalf(t, tt.wantLine, line, "LineColumn(%v) line", tt.at)
+			assert.Equalf(t, tt.wantCol, col, "LineColumn(%v) column", tt.at)
+		})
+	}
+}
+
 func TestLeaf_NewLineCount(t *testing.T) {
 	tests := []struct {
 		name string
@@ -366,6 +689,122 @@ func TestLeaf_NewLineCount(t *testing.T) {
 	}
 }
 
+func TestLeaf_Offset(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		line int
+		col  int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			line: 0,
+			col:  0,
+			want: 0,
+		},
+		{
+			name: "first line",
+			data: []rune("abc\ndef"),
+			line: 0,
+			col:  2,
+			want: 2,
+		},
+		{
+			name: "second line",
+			data: []rune("abc\ndef"),
+			line: 1,
+			col:  1,
+			want: 5,
+		},
+		{
+			name: "column after end of line",
+			data: []rune("abc\ndef"),
+			line: 0,
+			col:  10,
+			want: 3,
+		},
+		{
+			name: "column after end of last line",
+			data: []rune("abc\ndef"),
+			line: 1,
+			col:  10,
+			want: 7,
+		},
+		{
+			name: "negative column",
+			data: []rune("abc\ndef"),
+			line: 1,
+			col:  -1,
+			want: 4,
+		},
+		{
+			name: "line after end",
+			data: []rune("abc\ndef"),
+			line: 2,
+			col:  0,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, newLeaf(tt.data).Offset(tt.line, tt.col), "Offset(%v, %v)", tt.line, tt.col)
+		})
+	}
+}
+
+func TestLeaf_OffsetOfLine(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		line int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			line: 0,
+			want: 0,
+		},
+		{
+			name: "-1",
+			data: []rune("abc"),
+			line: -1,
+			want: -1,
+		},
+		{
+			name: "0 of 3",
+			data: []rune("abc\ndef\nghi"),
+			line: 0,
+			want: 0,
+		},
+		{
+			name: "2 of 3",
+			data: []rune("abc\ndef\nghi"),
+			line: 2,
+			want: 8,
+		},
+		{
+			name: "empty last line",
+			data: []rune("abc\n"),
+			line: 1,
+			want: 4,
+		},
+		{
+			name: "3 of 3",
+			data: []rune("abc\ndef\nghi"),
+			line: 3,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, newLeaf(tt.data).OffsetOfLine(tt.line), "OffsetOfLine(%v)", tt.line)
+		})
+	}
+}
+
 func TestLeaf_Prepend(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -393,7 +832,7 @@ func TestLeaf_Prepend(t *testing.T) {
 			start:     []rune(strings.Repeat("a", maxLeafSize)),
 			prepends:  []string{"b", "c", "d"},
 			want:      "dcb" + strings.Repeat("a", maxLeafSize),
-			wantDepth: 4,
+			wantDepth: 2,
 		},
 	}
 	for _, tt := range tests {
@@ -408,6 +847,103 @@ func TestLeaf_Prepend(t *testing.T) {
 	}
 }
 
+func TestLeaf_RuneToByte(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			data: []rune("aé€"),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "ascii",
+			data: []rune("aé€"),
+			at:   1,
+			want: 1,
+		},
+		{
+			name: "multi-byte",
+			data: []rune("aé€"),
+			at:   2,
+			want: 3,
+		},
+		{
+			name: "end",
+			data: []rune("aé€"),
+			at:   3,
+			want: 6,
+		},
+		{
+			name: "after end",
+			data: []rune("aé€"),
+			at:   4,
+			want: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeaf(tt.data)
+			assert.Equalf(t, tt.want, l.RuneToByte(tt.at), "RuneToByte(%v)", tt.at)
+		})
+	}
+}
+
+func TestLeaf_RuneToUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			data: []rune("a😀é"),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "surrogate pair",
+			data: []rune("a😀é"),
+			at:   2,
+			want: 3,
+		},
+		{
+			name: "end",
+			data: []rune("a😀é"),
+			at:   3,
+			want: 4,
+		},
+		{
+			name: "after end",
+			data: []rune("a😀é"),
+			at:   4,
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, newLeaf(tt.data).RuneToUTF16(tt.at), "RuneToUTF16(%v)", tt.at)
+		})
+	}
+}
+
 func TestLeaf_Split(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -469,6 +1005,52 @@ func TestLeaf_Split(t *testing.T) {
 	}
 }
 
+func TestLeaf_SplitAtByte(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []rune
+		at    int
+		want1 string
+		want2 string
+	}{
+		{
+			name:  "empty",
+			data:  nil,
+			at:    0,
+			want1: "",
+			want2: "",
+		},
+		{
+			name:  "start of rune",
+			data:  []rune("aé€"),
+			at:    1,
+			want1: "a",
+			want2: "é€",
+		},
+		{
+			name:  "inside rune",
+			data:  []rune("aé€"),
+			at:    4,
+			want1: "aé",
+			want2: "€",
+		},
+		{
+			name:  "after end",
+			data:  []rune("aé€"),
+			at:    7,
+			want1: "aé€",
+			want2: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got1, got2 := newLeaf(tt.data).SplitAtByte(tt.at)
+			assert.Equal(t, tt.want1, got1.String())
+			assert.Equal(t, tt.want2, got2.String())
+		})
+	}
+}
+
 func TestLeaf_String(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -567,3 +1149,111 @@ func TestLeaf_Sub(t *testing.T) {
 		})
 	}
 }
+
+func TestLeaf_UTF16Length(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: 0,
+		},
+		{
+			name: "basic multilingual plane",
+			data: []rune("aé€"),
+			want: 3,
+		},
+		{
+			name: "surrogate pair",
+			data: []rune("a😀"),
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, newLeaf(tt.data).UTF16Length(), "UTF16Length()")
+		})
+	}
+}
+
+func TestLeaf_UTF16ToRune(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			data: nil,
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			data: []rune("a😀é"),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "start of surrogate pair",
+			data: []rune("a😀é"),
+			at:   1,
+			want: 1,
+		},
+		{
+			name: "inside surrogate pair",
+			data: []rune("a😀é"),
+			at:   2,
+			want: 1,
+		},
+		{
+			name: "after surrogate pair",
+			data: []rune("a😀é"),
+			at:   3,
+			want: 2,
+		},
+		{
+			name: "after end",
+			data: []rune("a😀é"),
+			at:   5,
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, newLeaf(tt.data).UTF16ToRune(tt.at), "UTF16ToRune(%v)", tt.at)
+		})
+	}
+}
+
+func TestLeaf_WriteTo(t *testing.T) {
+	tests := []struct {
+		name string
+		data []rune
+		want string
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: "",
+		},
+		{
+			name: "multi-byte",
+			data: []rune("héllo €😀"),
+			want: "héllo €😀",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := newLeaf(tt.data).WriteTo(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.want)), n)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
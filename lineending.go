@@ -0,0 +1,351 @@
+package rope
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineEnding is a style of line break.
+type LineEnding int
+
+const (
+	// LineEndingNone is reported for text without line breaks, and leaves line breaks as they are when converting.
+	LineEndingNone LineEnding = iota
+	// LineEndingLF breaks lines with "\n", as on Unix.
+	LineEndingLF
+	// LineEndingCRLF breaks lines with "\r\n", as on Windows.
+	LineEndingCRLF
+	// LineEndingCR breaks lines with "\r", as on classic Mac OS.
+	LineEndingCR
+	// LineEndingMixed is reported for text which uses more than one style of line break.
+	LineEndingMixed
+)
+
+func (e LineEnding) String() string {
+	switch e {
+	case LineEndingNone:
+		return "none"
+	case LineEndingLF:
+		return "LF"
+	case LineEndingCRLF:
+		return "CRLF"
+	case LineEndingCR:
+		return "CR"
+	case LineEndingMixed:
+		return "mixed"
+	default:
+		return fmt.Sprintf("LineEnding(%d)", int(e))
+	}
+}
+
+// text returns the line break of the style.
+func (e LineEnding) text() string {
+	switch e {
+	case LineEndingNone:
+		return ""
+	case LineEndingLF:
+		return "\n"
+	case LineEndingCRLF:
+		return "\r\n"
+	case LineEndingCR:
+		return "\r"
+	case LineEndingMixed:
+		return ""
+	default:
+		return ""
+	}
+}
+
+// LineEndingReport describes the line breaks of a rope.
+type LineEndingReport struct {
+	LF   int // the number of "\n" line breaks
+	CRLF int // the number of "\r\n" line breaks
+	CR   int // the number of "\r" line breaks which are not followed by "\n"
+	// Dominant is the most common style of line break, preferring LF and then CRLF in a tie, or LineEndingNone if
+	// there are no line breaks.
+	Dominant LineEnding
+	// Mixed lists the (zero-based) lines which end with a line break of another style than Dominant.
+	Mixed []int
+}
+
+// lineBreaks counts the line breaks of a tree in each style. A "\r" which ends a tree and a "\n" which begins one
+// are each counted as a line break of its own, as they only form a single "\r\n" once the trees are joined.
+type lineBreaks struct {
+	breakCounts
+	firstLF bool // whether the tree begins with "\n"
+	lastCR  bool // whether the tree ends with "\r"
+}
+
+// breakCounts holds the number of line breaks of each style. Leaves store only these, as whether their text begins
+// with "\n" or ends with "\r" can be read from it, which keeps them small.
+type breakCounts struct {
+	lf, crlf, cr int
+}
+
+// count returns the number of line breaks.
+func (b breakCounts) count() int {
+	return b.lf + b.crlf + b.cr
+}
+
+// countBreaks counts the line breaks of a text.
+func countBreaks(s string) lineBreaks {
+	if s == "" {
+		return lineBreaks{}
+	}
+	b := lineBreaks{
+		breakCounts: breakCounts{lf: strings.Count(s, "\n")},
+		firstLF:     s[0] == '\n',
+		lastCR:      s[len(s)-1] == '\r',
+	}
+	if cr := strings.Count(s, "\r"); cr > 0 {
+		b.crlf = strings.Count(s, "\r\n")
+		b.lf -= b.crlf
+		b.cr = cr - b.crlf
+	}
+	return b
+}
+
+// breakCounter counts the line breaks of consecutive trees, joining a "\r" which ends one tree and a "\n" which
+// begins the next into a single line break.
+type breakCounter struct {
+	breaks  lineBreaks
+	started bool // whether any text has been counted
+}
+
+func (c *breakCounter) add(r Rope) {
+	if r.Length() == 0 {
+		return
+	}
+	b := r.breaks()
+	if !c.started {
+		c.breaks = b
+		c.started = true
+		return
+	}
+	joined := lineBreaks{
+		breakCounts: breakCounts{
+			lf:   c.breaks.lf + b.lf,
+			crlf: c.breaks.crlf + b.crlf,
+			cr:   c.breaks.cr + b.cr,
+		},
+		firstLF: c.breaks.firstLF,
+		lastCR:  b.lastCR,
+	}
+	if c.breaks.lastCR && b.firstLF {
+		joined.lf--
+		joined.cr--
+		joined.crlf++
+	}
+	c.breaks = joined
+}
+
+// joinBreaks counts the line breaks of the concatenation of trees.
+func joinBreaks(trees ...Rope) lineBreaks {
+	var c breakCounter
+	for _, r := range trees {
+		c.add(r)
+	}
+	return c.breaks
+}
+
+// splitsBreak reports whether a "\r\n" is split between two adjacent trees.
+func splitsBreak(l, r Rope) bool {
+	return l.breaks().lastCR && r.breaks().firstLF
+}
+
+// nextBreak returns the byte offset and size of the first line break of a text, or -1 if it has none. A "\r" at
+// the end of the text is a line break of its own.
+func nextBreak(s string) (int, int) {
+	end := strings.IndexByte(s, '\n')
+	if end < 0 {
+		end = len(s)
+	}
+	if i := strings.IndexByte(s[:end], '\r'); i >= 0 {
+		if i+1 < len(s) && s[i+1] == '\n' {
+			return i, 2
+		}
+		return i, 1
+	}
+	if end == len(s) {
+		return -1, 0
+	}
+	return end, 1
+}
+
+// lineStart returns the byte offset of the start of the last line of a text.
+func lineStart(s string) int {
+	return max(strings.LastIndexByte(s, '\n'), strings.LastIndexByte(s, '\r')) + 1
+}
+
+// lineEnd returns the index of the line break before next, the start of a line, excluding both runes of a
+// "\r\n" which starts after start.
+func lineEnd(r Rope, start, next int) int {
+	end := next - 1
+	if end > start && r.At(end) == '\n' && r.At(end-1) == '\r' {
+		end--
+	}
+	return end
+}
+
+// lineEnding finds the style of the line breaks of a tree.
+func lineEnding(r Rope) LineEnding {
+	b := r.breaks()
+	switch b.count() {
+	case 0:
+		return LineEndingNone
+	case b.lf:
+		return LineEndingLF
+	case b.crlf:
+		return LineEndingCRLF
+	case b.cr:
+		return LineEndingCR
+	default:
+		return LineEndingMixed
+	}
+}
+
+// lineEndings reports the line breaks of a tree, only reading its text if it mixes styles of line break.
+func lineEndings(r Rope) LineEndingReport {
+	b := r.breaks()
+	report := LineEndingReport{
+		LF:   b.lf,
+		CRLF: b.crlf,
+		CR:   b.cr,
+	}
+	switch {
+	case b.count() == 0:
+		report.Dominant = LineEndingNone
+	case b.lf >= b.crlf && b.lf >= b.cr:
+		report.Dominant = LineEndingLF
+	case b.crlf >= b.cr:
+		report.Dominant = LineEndingCRLF
+	default:
+		report.Dominant = LineEndingCR
+	}
+	if lineEnding(r) == LineEndingMixed {
+		report.Mixed = mixedLines(r, report.Dominant)
+	}
+	return report
+}
+
+// mixedLines returns the (zero-based) lines of a tree which end with a line break of another style than dominant.
+func mixedLines(r Rope, dominant LineEnding) []int {
+	var mixed []int
+	var line int
+	found := func(style LineEnding) {
+		if style != dominant {
+			mixed = append(mixed, line)
+		}
+		line++
+	}
+	var cr bool // whether the last text ended with "\r", which may be followed by "\n"
+	for text := range textInRange(r, 0, r.Length()) {
+		if cr {
+			cr = false
+			if text[0] == '\n' {
+				found(LineEndingCRLF)
+				text = text[1:]
+			} else {
+				found(LineEndingCR)
+			}
+		}
+		for {
+			i := strings.IndexAny(text, "\r\n")
+			if i < 0 {
+				break
+			}
+			switch {
+			case text[i] == '\n':
+				found(LineEndingLF)
+			case i+1 == len(text):
+				cr = true
+			case text[i+1] == '\n':
+				found(LineEndingCRLF)
+				i++
+			default:
+				found(LineEndingCR)
+			}
+			text = text[i+1:]
+		}
+	}
+	if cr {
+		found(LineEndingCR)
+	}
+	return mixed
+}
+
+// normalizeLineEndings converts the line breaks of a tree to the given style, returning the tree itself if there is
+// nothing to convert.
+func normalizeLineEndings(r Rope, e LineEnding) Rope {
+	ending := e.text()
+	if ending == "" {
+		return r
+	}
+	if current := lineEnding(r); current == LineEndingNone || current == e {
+		return r
+	}
+	b := builder{opts: r.options()}
+	lw := lineEndingWriter{w: &b, ending: ending}
+	for text := range textInRange(r, 0, r.Length()) {
+		_, _ = lw.WriteString(text)
+	}
+	_ = lw.flush()
+	return b.rope()
+}
+
+// lineEndingWriter converts the line breaks of the text written to it. A "\r" at the end of one write may be
+// followed by a "\n" at the start of the next, so it is held back until the next write or flush.
+type lineEndingWriter struct {
+	w      io.StringWriter
+	ending string
+	cr     bool // whether a "\r" has been held back
+}
+
+func (lw *lineEndingWriter) Write(p []byte) (int, error) {
+	return lw.WriteString(string(p))
+}
+
+func (lw *lineEndingWriter) WriteString(s string) (int, error) {
+	n := len(s)
+	for s != "" {
+		if lw.cr && s[0] == '\n' {
+			// the held back "\r" and this "\n" are a single line break
+			s = s[1:]
+		}
+		if err := lw.flush(); err != nil {
+			return 0, err
+		}
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			i = len(s)
+		}
+		if _, err := lw.w.WriteString(s[:i]); err != nil {
+			return 0, fmt.Errorf("error writing: %w", err)
+		}
+		if i == len(s) {
+			break
+		}
+		// a "\n" is written at once, while a "\r" is held back in case a "\n" follows it
+		lw.cr = true
+		if s[i] == '\n' {
+			if err := lw.flush(); err != nil {
+				return 0, err
+			}
+		}
+		s = s[i+1:]
+	}
+	return n, nil
+}
+
+// flush writes a held back "\r".
+func (lw *lineEndingWriter) flush() error {
+	if !lw.cr {
+		return nil
+	}
+	lw.cr = false
+	if _, err := lw.w.WriteString(lw.ending); err != nil {
+		return fmt.Errorf("error writing: %w", err)
+	}
+	return nil
+}
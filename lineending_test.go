@@ -0,0 +1,138 @@
+package rope
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRope_LineBreaks(t *testing.T) {
+	input := "a\rb\r\nc\nd\r\r\n\ne\r"
+	wantLines := []string{"a", "b", "c", "d", "", "", "e", ""}
+	wantOffsets := []int{0, 2, 5, 7, 9, 11, 12, 14}
+	for _, backend := range testBackends {
+		for _, size := range []int{1, 2, 3, 256} {
+			o := backend.options
+			o.MaxLeafSize = size
+			r := FromStringWithOptions(input, o)
+			t.Run(fmt.Sprintf("%s leaf size %d", backend.name, size), func(t *testing.T) {
+				require.Equal(t, len(wantLines)-1, r.NewLineCount())
+				for line, want := range wantLines {
+					assert.Equal(t, want, r.Line(line).String(), "Line(%d)", line)
+					assert.Equal(t, wantOffsets[line], r.OffsetOfLine(line), "OffsetOfLine(%d)", line)
+				}
+				assert.Equal(t, -1, r.OffsetOfLine(len(wantLines)))
+
+				// the "\n" of a "\r\n" is on the same line as the "\r"
+				for i, want := range [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {1, 2}, {2, 0}, {2, 1}, {3, 0}, {3, 1},
+					{4, 0}, {4, 1}, {5, 0}, {6, 0}, {6, 1}, {7, 0}} {
+					line, col := r.LineColumn(i)
+					assert.Equal(t, want, []int{line, col}, "LineColumn(%d)", i)
+				}
+
+				var lines []string
+				for _, line := range r.Lines(0, len(wantLines)).All() {
+					lines = append(lines, line.String())
+				}
+				assert.Equal(t, wantLines, lines)
+			})
+		}
+	}
+}
+
+func TestRope_LineEnding(t *testing.T) {
+	tests := []struct {
+		name  string
+		rope  Rope
+		want  LineEnding
+		lines int
+	}{
+		{name: "empty", rope: FromString(""), want: LineEndingNone},
+		{name: "no line breaks", rope: FromString("abc"), want: LineEndingNone},
+		{name: "lf", rope: FromString("a\nb\n"), want: LineEndingLF, lines: 2},
+		{name: "crlf", rope: FromString("a\r\nb\r\n"), want: LineEndingCRLF, lines: 2},
+		{name: "cr", rope: FromString("a\rb\r"), want: LineEndingCR, lines: 2},
+		{name: "mixed", rope: FromString("a\r\nb\nc"), want: LineEndingMixed, lines: 2},
+		{name: "crlf across leaves", rope: newNode(FromString("a\r"), FromString("\nb")), want: LineEndingCRLF, lines: 1},
+		{
+			name:  "crlf across empty leaves",
+			rope:  FromStringWithOptions("a\r", Options{MaxLeafSize: 1}).Append(FromString("")).Append(FromString("\n")),
+			want:  LineEndingCRLF,
+			lines: 1,
+		},
+		{name: "cr across leaves", rope: newNode(FromString("a\r"), FromString("b\n")), want: LineEndingMixed, lines: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rope.LineEnding())
+			assert.Equal(t, tt.lines, tt.rope.NewLineCount())
+		})
+	}
+}
+
+func TestRope_LineEndings(t *testing.T) {
+	input := "a\r\nb\nc\r\nd\re\r\n\r"
+	for _, size := range []int{1, 2, 256} {
+		r := FromStringWithOptions(input, Options{MaxLeafSize: size})
+		assert.Equal(t, LineEndingReport{
+			LF:       1,
+			CRLF:     3,
+			CR:       2,
+			Dominant: LineEndingCRLF,
+			Mixed:    []int{1, 3, 5},
+		}, r.LineEndings(), "leaf size %d", size)
+	}
+
+	assert.Equal(t, LineEndingReport{LF: 2, Dominant: LineEndingLF}, FromString("a\nb\n").LineEndings())
+	assert.Equal(t, LineEndingReport{Dominant: LineEndingNone}, FromString("abc").LineEndings())
+	// ties prefer LF, then CRLF
+	assert.Equal(t, LineEndingReport{LF: 1, CR: 1, Dominant: LineEndingLF, Mixed: []int{0}},
+		FromString("a\rb\n").LineEndings())
+	assert.Equal(t, LineEndingReport{CRLF: 1, CR: 1, Dominant: LineEndingCRLF, Mixed: []int{1}},
+		FromString("a\r\nb\r").LineEndings())
+}
+
+func TestRope_NormalizeLineEndings(t *testing.T) {
+	input := "a\r\nb\nc\r\rd\r"
+	tests := []struct {
+		ending LineEnding
+		want   string
+	}{
+		{ending: LineEndingLF, want: "a\nb\nc\n\nd\n"},
+		{ending: LineEndingCRLF, want: "a\r\nb\r\nc\r\n\r\nd\r\n"},
+		{ending: LineEndingCR, want: "a\rb\rc\r\rd\r"},
+		{ending: LineEndingNone, want: input},
+		{ending: LineEndingMixed, want: input},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ending.String(), func(t *testing.T) {
+			for _, backend := range testBackends {
+				r := FromStringWithOptions(input, backend.options)
+				got := r.NormalizeLineEndings(tt.ending)
+				assert.Equal(t, tt.want, got.String(), backend.name)
+				assert.Equal(t, r.Options(), got.Options(), backend.name)
+				requireStructure(t, got, backend.name)
+			}
+		})
+	}
+
+	// a rope which needs no conversion is returned as it is, sharing its leaves
+	for _, r := range []Rope{
+		FromStringWithOptions(strings.Repeat("abc\n", 100), Options{MaxLeafSize: 16}),
+		FromStringWithOptions(strings.Repeat("abc", 100), Options{MaxLeafSize: 16}),
+	} {
+		got := r.NormalizeLineEndings(LineEndingLF)
+		require.Len(t, got.leaves(), len(r.leaves()))
+		for i, leaf := range got.leaves() {
+			assert.Same(t, unsafe.StringData(r.leaves()[i].String()), unsafe.StringData(leaf.String()))
+		}
+	}
+
+	lossless, err := FromReaderWithOptions(strings.NewReader("caf\xe9\r\n\xff\r"), Options{Lossless: true})
+	require.NoError(t, err)
+	assert.Equal(t, "caf\xe9\n\xff\n", lossless.NormalizeLineEndings(LineEndingLF).String())
+}
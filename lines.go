@@ -0,0 +1,114 @@
+package rope
+
+import (
+	"iter"
+	"unicode/utf8"
+)
+
+// LineIterator streams consecutive lines of a rope in a single traversal.
+// Lines are broken by "\n", "\r\n" or a "\r" which is not followed by "\n", and the line breaks are not included
+// in the lines. The final line is yielded even if it is not terminated by a line break.
+type LineIterator struct {
+	rope  Rope
+	it    *Iterator
+	index int // index of the next line
+	to    int
+	line  Rope
+}
+
+func newLineIterator(r Rope, from, to int) *LineIterator {
+	if from < 0 {
+		from = 0
+	}
+	if count := r.NewLineCount() + 1; to > count {
+		to = count
+	}
+	li := &LineIterator{
+		rope:  r,
+		index: from,
+		to:    to,
+	}
+	if from < to {
+		li.it = r.IterAt(r.OffsetOfLine(from))
+	}
+	return li
+}
+
+// Next reads the next line, returning false if there are no more lines in the range.
+func (li *LineIterator) Next() bool {
+	if li.index >= li.to {
+		li.line = nil
+		return false
+	}
+	// the line is built from the leaves of the rope and substrings of their text rather than from its runes, so
+	// that it shares memory with the rope and keeps any invalid bytes
+	it := li.it
+	var pieces []Rope
+	for {
+		if it.pos == it.length {
+			if !it.nextLeaf() {
+				break
+			}
+			continue
+		}
+		text := it.data[it.offset:]
+		i, size := nextBreak(text)
+		if i < 0 {
+			pieces = append(pieces, li.piece(len(it.data)))
+			it.pos, it.offset = it.length, len(it.data)
+			continue
+		}
+		if i > 0 {
+			pieces = append(pieces, li.piece(it.offset+i))
+		}
+		it.pos += utf8.RuneCountInString(text[:i+size])
+		it.offset += i + size
+		if size == 1 && text[i] == '\r' && it.pos == it.length {
+			// skip the "\n" of a "\r\n" which is split between leaves
+			if next, ok := it.Next(); ok && next != '\n' {
+				it.Prev()
+			}
+		}
+		break
+	}
+	switch len(pieces) {
+	case 0:
+		li.line = li.rope.options().newLeaf("")
+	case 1:
+		li.line = pieces[0]
+	default:
+		li.line = li.rope.options().build(pieces)
+	}
+	li.index++
+	return true
+}
+
+// piece returns the text of the current leaf from the cursor to the given byte offset, which is the leaf itself if
+// that is all of its text.
+func (li *LineIterator) piece(end int) Rope {
+	if li.it.offset == 0 && end == len(li.it.data) {
+		return li.it.leaf()
+	}
+	return li.rope.options().newLeaf(li.it.data[li.it.offset:end])
+}
+
+// Line returns the line read by the last call to Next.
+func (li *LineIterator) Line() Rope {
+	return li.line
+}
+
+// Index returns the (zero-based) index of the line read by the last call to Next.
+func (li *LineIterator) Index() int {
+	return li.index - 1
+}
+
+// All returns an iterator over the index and content of each remaining line.
+func (li *LineIterator) All() iter.Seq2[int, Rope] {
+	return func(yield func(int, Rope) bool) {
+		for li.Next() {
+			if !yield(li.Index(), li.Line()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineIterator(t *testing.T) {
+	tests := []struct {
+		name     string
+		rope     Rope
+		from, to int
+		want     []string
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			from: 0,
+			to:   10,
+			want: []string{""},
+		},
+		{
+			name: "single line",
+			rope: FromString("abc"),
+			from: 0,
+			to:   1,
+			want: []string{"abc"},
+		},
+		{
+			name: "no trailing new line",
+			rope: FromString("abc\ndef\nghi"),
+			from: 0,
+			to:   3,
+			want: []string{"abc", "def", "ghi"},
+		},
+		{
+			name: "trailing new line",
+			rope: FromString("abc\ndef\n"),
+			from: 0,
+			to:   3,
+			want: []string{"abc", "def", ""},
+		},
+		{
+			name: "crlf",
+			rope: FromString("abc\r\ndef\r\nghi"),
+			from: 0,
+			to:   3,
+			want: []string{"abc", "def", "ghi"},
+		},
+		{
+			name: "lone carriage returns",
+			rope: FromString("a\rb\r\r\nc\r"),
+			from: 0,
+			to:   5,
+			want: []string{"a", "b", "", "c", ""},
+		},
+		{
+			name: "crlf across leaves",
+			rope: newNode(FromString("abc\r"), FromString("\ndef")),
+			from: 0,
+			to:   2,
+			want: []string{"abc", "def"},
+		},
+		{
+			name: "range across nodes",
+			rope: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			from: 1,
+			to:   4,
+			want: []string{"def", "ghi", "jkl"},
+		},
+		{
+			name: "to after end",
+			rope: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			from: 3,
+			to:   10,
+			want: []string{"jkl", "mno"},
+		},
+		{
+			name: "from after end",
+			rope: FromString("abc\ndef"),
+			from: 2,
+			to:   10,
+			want: nil,
+		},
+		{
+			name: "from before start",
+			rope: FromString("abc\ndef"),
+			from: -1,
+			to:   1,
+			want: []string{"abc"},
+		},
+		{
+			name: "long line",
+			rope: FromString(strings.Repeat("a", maxLeafSize*3) + "\nb"),
+			from: 0,
+			to:   2,
+			want: []string{strings.Repeat("a", maxLeafSize*3), "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			li := tt.rope.Lines(tt.from, tt.to)
+			for li.Next() {
+				assert.Equal(t, max(tt.from, 0)+len(got), li.Index())
+				got = append(got, li.Line().String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLineIterator_All(t *testing.T) {
+	r := newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno"))
+
+	var indexes []int
+	var lines []string
+	for i, line := range r.Lines(1, 5).All() {
+		if i == 3 {
+			break
+		}
+		indexes = append(indexes, i)
+		lines = append(lines, line.String())
+	}
+	assert.Equal(t, []int{1, 2}, indexes)
+	assert.Equal(t, []string{"def", "ghi"}, lines)
+}
+
+func TestLineIterator_SharesLeaves(t *testing.T) {
+	text := strings.Repeat(strings.Repeat("héllo ", 100)+"\r\n"+strings.Repeat("x", 300)+"\r", 20)
+	r := FromStringWithOptions(text, Options{MaxLeafSize: 64})
+
+	// every chunk of every line lies within the text of a leaf of the rope
+	var leaves []string
+	for chunk := range r.Chunks() {
+		leaves = append(leaves, chunk)
+	}
+	within := func(chunk string) bool {
+		p := uintptr(unsafe.Pointer(unsafe.StringData(chunk)))
+		for _, leaf := range leaves {
+			start := uintptr(unsafe.Pointer(unsafe.StringData(leaf)))
+			if p >= start && p+uintptr(len(chunk)) <= start+uintptr(len(leaf)) {
+				return true
+			}
+		}
+		return false
+	}
+	var count int
+	for i, line := range r.Lines(0, r.NewLineCount()+1).All() {
+		require.Equal(t, r.Line(i).String(), line.String())
+		for chunk := range line.Chunks() {
+			require.True(t, within(chunk), "line %d", i)
+		}
+		count++
+	}
+	assert.Equal(t, 41, count)
+}
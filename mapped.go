@@ -0,0 +1,474 @@
+package rope
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"runtime"
+	"unicode/utf8"
+)
+
+var _ Rope = (*LazyLeaf)(nil)
+
+// lazyLeafSize is the default number of bytes of the source held by each lazy leaf.
+const lazyLeafSize = 64 * 1024
+
+// LazyLeaf is a leaf of a rope created by FromReaderAt or FromFileMapped, which refers to a range of bytes of its
+// source. Its counts are found when the rope is created, and its text is read from the source whenever it is needed
+// without being kept. Edits at its ends, and any edits elsewhere in the rope, leave it unread, so a rope only ever
+// holds copies of the parts of its source which have been edited.
+type LazyLeaf struct {
+	text        *lazyText
+	length      int
+	lineBreaks  lineBreaks
+	byteLength  int
+	utf16Length int
+	invalid     bool // whether the source holds invalid bytes which are kept by the Lossless option
+	opts        *Options
+}
+
+// lazyText is the range of the source held by a lazy leaf, which is shared by its copies.
+type lazyText struct {
+	source *source
+	offset int64
+	size   int
+}
+
+// source is the input of a lazily read rope. It is released, unmapping a mapped file, once no lazy leaf refers to
+// it. This is safe because the text of a leaf is always copied out of the source, and never refers to it.
+type source struct {
+	io.ReaderAt
+	release func()
+}
+
+func newSource(r io.ReaderAt, release func()) *source {
+	s := &source{
+		ReaderAt: r,
+		release:  release,
+	}
+	if release != nil {
+		runtime.SetFinalizer(s, func(s *source) { s.release() })
+	}
+	return s
+}
+
+// FromReaderAt creates a rope from the first size bytes of a reader, which must not change while the rope, or any
+// rope derived from it, is in use. The input is scanned once to count its runes and lines, but the text of each
+// leaf is only read when it is needed, so ropes can be created for inputs far larger than memory. Methods which
+// need text which can no longer be read from the reader panic.
+func FromReaderAt(r io.ReaderAt, size int64) (Rope, error) {
+	return fromSource(newSource(r, nil), size, defaultOptions)
+}
+
+// FromFileMapped creates a rope from a file which is mapped into memory and read lazily, as with FromReaderAt. The
+// file must not be modified while the rope is in use, and it is unmapped once no rope refers to it. On platforms
+// without memory mapping, the file is kept open and read instead.
+func FromFileMapped(path string) (Rope, error) {
+	return fromFileMapped(path, defaultOptions)
+}
+
+// fromSource scans a source, cutting it into lazy leaves on rune boundaries, which are assembled into a balanced
+// tree.
+func fromSource(src *source, size int64, o *Options) (Rope, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+	var leaves []Rope
+	leaf := LazyLeaf{opts: o}
+	var start int64 // the offset of the first byte of the leaf
+	emit := func(end int64) {
+		leaf.text = &lazyText{
+			source: src,
+			offset: start,
+			size:   int(end - start),
+		}
+		emitted := leaf
+		leaves = append(leaves, &emitted)
+		leaf = LazyLeaf{opts: o}
+		start = end
+	}
+
+	buf := make([]byte, readSize)
+	var pos int64 // the offset of buf[0]
+	var pending int
+	for {
+		want := int(min(int64(len(buf)-pending), size-pos-int64(pending)))
+		n, err := src.ReadAt(buf[pending:pending+want], pos+int64(pending))
+		if n < want {
+			return nil, fmt.Errorf("error reading: %w", err)
+		}
+		n += pending
+		end := pos+int64(n) == size
+		var i int
+		for i < n {
+			// wait for the rest of an incomplete rune, unless there is no more input to come
+			if !end && !utf8.FullRune(buf[i:n]) {
+				break
+			}
+			c, width := utf8.DecodeRune(buf[i:n])
+			leaf.add(c)
+			switch {
+			case c == utf8.RuneError && width == 1 && o.Lossless:
+				leaf.invalid = true
+			case c == utf8.RuneError && width == 1:
+				// the invalid byte will be replaced
+				leaf.byteLength += utf8.RuneLen(utf8.RuneError) - width
+			}
+			leaf.byteLength += width
+			i += width
+			if pos+int64(i)-start >= int64(o.LazyLeafSize) {
+				emit(pos + int64(i))
+			}
+		}
+		pending = copy(buf, buf[i:n])
+		pos += int64(i)
+		if end {
+			break
+		}
+	}
+	if leaf.length > 0 {
+		emit(size)
+	}
+	return o.build(leaves), nil
+}
+
+// add adds a rune to the counts of the leaf, as it is scanned.
+func (l *LazyLeaf) add(c rune) {
+	b := &l.lineBreaks
+	switch {
+	case c == '\n' && b.lastCR:
+		b.cr--
+		b.crlf++
+	case c == '\n':
+		b.lf++
+		b.firstLF = b.firstLF || l.length == 0
+	case c == '\r':
+		b.cr++
+	}
+	b.lastCR = c == '\r'
+	l.length++
+	l.utf16Length += utf16Len(c)
+}
+
+// view reads the text of the leaf into an ordinary leaf, which is not kept, for queries which need more than the
+// text itself.
+func (l LazyLeaf) view() Rope {
+	leaf := l.options().newLeaf(l.peek())
+	if leaf.Length() != l.length {
+		panic("rope: the source of a lazy leaf has changed")
+	}
+	return leaf
+}
+
+// peek reads the text of the leaf, without keeping it.
+func (l LazyLeaf) peek() string {
+	text, err := l.read()
+	if err != nil {
+		panic(fmt.Sprintf("rope: failed to read lazy leaf: %v", err))
+	}
+	if len(text) != l.byteLength {
+		panic("rope: the source of a lazy leaf has changed")
+	}
+	return text
+}
+
+// split cuts the text of the leaf before the given index into leaves of at most MaxLeafSize runes.
+func (l LazyLeaf) split(text string, at int) (Rope, Rope) {
+	b := byteOffset(text, l.length, at)
+	o := l.options()
+	return o.fromString(text[:b]), o.fromString(text[b:])
+}
+
+// read copies the text of the leaf out of its source, replacing invalid bytes unless the options are lossless.
+func (l LazyLeaf) read() (string, error) {
+	t := l.text
+	buf := make([]byte, t.size)
+	n, err := t.source.ReadAt(buf, t.offset)
+	// the source must not be released, unmapping it, while it is read
+	runtime.KeepAlive(t.source)
+	if n < len(buf) {
+		return "", fmt.Errorf("error reading: %w", err)
+	}
+	if !l.options().Lossless && !utf8.Valid(buf) {
+		return string([]rune(string(buf))), nil
+	}
+	return string(buf), nil
+}
+
+// isLazy reports whether a tree is a lazy leaf.
+func isLazy(r Rope) bool {
+	_, ok := r.(*LazyLeaf)
+	return ok
+}
+
+// leafString returns the text of a leaf, reading the text of a lazy leaf without keeping it, so that a rope can be
+// written or converted to a string without holding a second copy of its text.
+func leafString(leaf Rope) string {
+	if l, ok := leaf.(*LazyLeaf); ok {
+		return l.peek()
+	}
+	return leaf.String()
+}
+
+func (l LazyLeaf) Options() Options {
+	return *l.options()
+}
+
+func (l LazyLeaf) options() *Options {
+	if l.opts == nil {
+		return defaultOptions
+	}
+	return l.opts
+}
+
+func (l LazyLeaf) String() string {
+	return l.peek()
+}
+
+func (l LazyLeaf) Length() int {
+	return l.length
+}
+
+func (l LazyLeaf) Append(n Rope) Rope {
+	o := l.options()
+	n = o.adopt(n)
+	if l.length+n.Length() <= o.MaxLeafSize {
+		return l.view().Append(n)
+	}
+	return o.autoBalance(o.join(&l, n))
+}
+
+func (l LazyLeaf) Prepend(n Rope) Rope {
+	o := l.options()
+	n = o.adopt(n)
+	if l.length+n.Length() <= o.MaxLeafSize {
+		return l.view().Prepend(n)
+	}
+	return o.autoBalance(o.join(n, &l))
+}
+
+func (l LazyLeaf) Split(at int) (Rope, Rope) {
+	switch {
+	case at <= 0:
+		return l.options().newLeaf(""), &l
+	case at >= l.length:
+		return &l, l.options().newLeaf("")
+	default:
+		return l.split(l.peek(), at)
+	}
+}
+
+func (l LazyLeaf) Sub(start, end int) Rope {
+	if start <= 0 && end >= l.length {
+		return &l
+	}
+	text := l.peek()
+	return l.options().fromString(text[byteOffset(text, l.length, start):byteOffset(text, l.length, end)])
+}
+
+func (l LazyLeaf) Index(r rune) int {
+	return l.view().Index(r)
+}
+
+func (l LazyLeaf) LastIndex(r rune) int {
+	return l.view().LastIndex(r)
+}
+
+func (l LazyLeaf) At(i int) rune {
+	return l.view().At(i)
+}
+
+func (l LazyLeaf) Line(line int) Rope {
+	// a line may be longer than MaxLeafSize, so it is cut into leaves
+	return l.options().fromString(l.view().Line(line).String())
+}
+
+func (l LazyLeaf) Balance() Rope {
+	return &l
+}
+
+func (l LazyLeaf) NewLineCount() int {
+	return l.lineBreaks.count()
+}
+
+func (l LazyLeaf) breaks() lineBreaks {
+	return l.lineBreaks
+}
+
+func (l LazyLeaf) Depth() int {
+	return 1
+}
+
+func (l LazyLeaf) leaves() []Rope {
+	return []Rope{&l}
+}
+
+func (l LazyLeaf) childCount() int {
+	return 0
+}
+
+func (l LazyLeaf) child(int) Rope {
+	return nil
+}
+
+func (l LazyLeaf) Data() []rune {
+	return l.view().Data()
+}
+
+func (l LazyLeaf) Insert(at int, r Rope) Rope {
+	switch {
+	case r.Length() == 0:
+		return &l
+	case at <= 0:
+		return l.Prepend(r)
+	case at >= l.length:
+		return l.Append(r)
+	default:
+		// the text is cut into leaves of at most MaxLeafSize runes, so that the edited region follows the size policy
+		return l.options().fromString(l.peek()).Insert(at, r)
+	}
+}
+
+func (l LazyLeaf) InsertString(at int, s string) Rope {
+	return l.Insert(at, l.options().fromString(s))
+}
+
+func (l LazyLeaf) Delete(start, end int) Rope {
+	switch {
+	case max(start, 0) >= min(end, l.length):
+		return &l
+	case start <= 0 && end >= l.length:
+		return l.options().newLeaf("")
+	default:
+		return l.options().fromString(l.peek()).Delete(start, end)
+	}
+}
+
+func (l LazyLeaf) ByteLength() int {
+	return l.byteLength
+}
+
+func (l LazyLeaf) RuneToByte(i int) int {
+	return l.view().RuneToByte(i)
+}
+
+func (l LazyLeaf) ByteToRune(b int) int {
+	return l.view().ByteToRune(b)
+}
+
+func (l LazyLeaf) SplitAtByte(b int) (Rope, Rope) {
+	leaf := l.view()
+	at := leaf.ByteToRune(b)
+	if at <= 0 || at >= l.length {
+		return l.Split(at)
+	}
+	return l.split(leaf.String(), at)
+}
+
+func (l LazyLeaf) OffsetOfLine(line int) int {
+	return l.view().OffsetOfLine(line)
+}
+
+func (l LazyLeaf) LineColumn(i int) (int, int) {
+	return l.view().LineColumn(i)
+}
+
+func (l LazyLeaf) Offset(line, col int) int {
+	return l.view().Offset(line, col)
+}
+
+func (l LazyLeaf) UTF16Length() int {
+	return l.utf16Length
+}
+
+func (l LazyLeaf) RuneToUTF16(i int) int {
+	return l.view().RuneToUTF16(i)
+}
+
+func (l LazyLeaf) UTF16ToRune(u int) int {
+	return l.view().UTF16ToRune(u)
+}
+
+func (l LazyLeaf) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(&l, w)
+}
+
+func (l LazyLeaf) IterAt(i int) *Iterator {
+	return l.view().IterAt(i)
+}
+
+func (l LazyLeaf) Runes() iter.Seq2[int, rune] {
+	return l.view().Runes()
+}
+
+func (l LazyLeaf) RunesFrom(i int) iter.Seq2[int, rune] {
+	return l.view().RunesFrom(i)
+}
+
+func (l LazyLeaf) Lines(from, to int) *LineIterator {
+	return l.view().Lines(from, to)
+}
+
+func (l LazyLeaf) Chunks() iter.Seq[string] {
+	return textInRange(&l, 0, l.length)
+}
+
+func (l LazyLeaf) ChunksInRange(start, end int) iter.Seq[string] {
+	return textInRange(&l, start, end)
+}
+
+func (l LazyLeaf) IndexString(s string) int {
+	return l.view().IndexString(s)
+}
+
+func (l LazyLeaf) IndexFrom(s string, from int) int {
+	return l.view().IndexFrom(s, from)
+}
+
+func (l LazyLeaf) LastIndexString(s string) int {
+	return l.view().LastIndexString(s)
+}
+
+func (l LazyLeaf) Count(s string) int {
+	return l.view().Count(s)
+}
+
+func (l LazyLeaf) FindRegexp(re *regexp.Regexp, from int) []int {
+	return l.view().FindRegexp(re, from)
+}
+
+func (l LazyLeaf) FindAllRegexp(re *regexp.Regexp, limit int) [][]int {
+	return l.view().FindAllRegexp(re, limit)
+}
+
+func (l LazyLeaf) InvalidRanges() [][]int {
+	if !l.invalid {
+		return nil
+	}
+	return l.view().InvalidRanges()
+}
+
+func (l LazyLeaf) LineEnding() LineEnding {
+	return lineEnding(&l)
+}
+
+func (l LazyLeaf) LineEndings() LineEndingReport {
+	return lineEndings(&l)
+}
+
+func (l LazyLeaf) NormalizeLineEndings(e LineEnding) Rope {
+	return normalizeLineEndings(&l, e)
+}
+
+func (l LazyLeaf) Replace(old, new string, limit int) (Rope, []Edit) {
+	return l.options().fromString(l.peek()).Replace(old, new, limit)
+}
+
+func (l LazyLeaf) ReplaceAll(old, new string) (Rope, []Edit) {
+	return l.options().fromString(l.peek()).ReplaceAll(old, new)
+}
+
+func (l LazyLeaf) ReplaceRegexp(re *regexp.Regexp, template string) (Rope, []Edit) {
+	return l.options().fromString(l.peek()).ReplaceRegexp(re, template)
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package rope
+
+import (
+	"fmt"
+	"os"
+)
+
+// fromFileMapped scans a file into a rope of lazy leaves. Memory mapping is not supported on this platform, so the
+// file is kept open and read from instead, until no rope refers to it.
+func fromFileMapped(path string, o *Options) (Rope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	src := newSource(f, func() { _ = f.Close() })
+	r, err := fromSource(src, info.Size(), o)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,241 @@
+package rope
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReaderAt records the offsets read from an underlying reader.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	mu    sync.Mutex
+	reads []int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	c.reads = append(c.reads, off)
+	c.mu.Unlock()
+	return c.r.ReadAt(p, off)
+}
+
+func (c *countingReaderAt) reset() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reads := c.reads
+	c.reads = nil
+	return reads
+}
+
+type failingReaderAt struct{}
+
+var errFailingReaderAt = errors.New("failing reader")
+
+func (failingReaderAt) ReadAt([]byte, int64) (int, error) {
+	return 0, errFailingReaderAt
+}
+
+func TestFromReaderAt_Conformance(t *testing.T) {
+	for _, backend := range testBackends {
+		for seed := int64(0); seed < 3; seed++ {
+			t.Run(fmt.Sprintf("%s seed %d", backend.name, seed), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(seed))
+				text := func() string {
+					runes := make([]rune, rng.Intn(30))
+					for i := range runes {
+						runes[i] = []rune("ab€😀\n\r")[rng.Intn(6)]
+					}
+					return string(runes)
+				}
+
+				input := text() + text() + text()
+				o := backend.options
+				o.LazyLeafSize = 7
+				r, err := FromReaderAtWithOptions(strings.NewReader(input), int64(len(input)), o)
+				require.NoError(t, err)
+				requireConformance(t, rng, r, FromString(input), "initially")
+
+				v := version{rope: r, want: []rune(input)}
+				var ops []string
+				for i := 0; i < 30; i++ {
+					var op string
+					v, op = randomEdit(rng, v, text)
+					ops = append(ops, op)
+					requireConformance(t, rng, v.rope, newLeaf(v.want), fmt.Sprintf("after %v", ops))
+				}
+			})
+		}
+	}
+}
+
+func TestFromReaderAt_Lazy(t *testing.T) {
+	const size = 16 * 17
+	input := strings.Repeat("0123456789abcdef\n", 256)
+	src := &countingReaderAt{r: strings.NewReader(input)}
+	r, err := FromReaderAtWithOptions(src, int64(len(input)), Options{LazyLeafSize: size})
+	require.NoError(t, err)
+	require.Len(t, r.leaves(), 16)
+	src.reset()
+
+	// the counts are known without reading
+	assert.Equal(t, len(input), r.Length())
+	assert.Equal(t, 256, r.NewLineCount())
+	assert.Equal(t, len(input), r.ByteLength())
+	assert.Equal(t, len(input), r.UTF16Length())
+	assert.Nil(t, r.InvalidRanges())
+	assert.Empty(t, src.reset())
+
+	// edits at the boundaries of leaves read nothing
+	edited := r.InsertString(3*size, "new").Delete(5*size+3, 6*size+3)
+	want := input[:3*size] + "new" + input[3*size:5*size] + input[6*size:]
+	assert.Empty(t, src.reset())
+
+	// an edit within a leaf reads only that leaf, and only once, cutting it into leaves of at most MaxLeafSize runes
+	edited = edited.InsertString(9*size+4, "new")
+	want = want[:9*size+4] + "new" + want[9*size+4:]
+	assert.Equal(t, []int64{10 * size}, src.reset())
+	assert.Equal(t, 'a', edited.At(9*size+16))
+	assert.Empty(t, src.reset())
+	for _, leaf := range edited.leaves() {
+		if _, ok := leaf.(*LazyLeaf); !ok {
+			assert.LessOrEqual(t, leaf.Length(), r.Options().MaxLeafSize)
+		}
+	}
+
+	// searching and writing read the leaves without keeping their text
+	assert.Equal(t, -1, r.IndexString("x"))
+	assert.Len(t, src.reset(), 16)
+	assert.Equal(t, LineEndingLF, r.LineEnding())
+	assert.Equal(t, 256, r.Count("\n"))
+	assert.Len(t, src.reset(), 16)
+
+	// queries within a leaf read it each time, without keeping its text
+	for range 2 {
+		assert.Equal(t, 'a', r.At(10*size+10))
+		assert.Equal(t, 10*size+17, r.OffsetOfLine(10*16+1))
+		assert.Equal(t, "0123456789abcdef", r.Line(10*16+2).String())
+		assert.Equal(t, []int64{10 * size, 10 * size, 10 * size}, src.reset())
+	}
+
+	// cutting a leaf reads it once, cutting its text into leaves of at most MaxLeafSize runes
+	left, right := r.Split(10*size + 4)
+	assert.Equal(t, []int64{10 * size}, src.reset())
+	sub := r.Sub(10*size+4, 11*size+4)
+	assert.Equal(t, []int64{10 * size, 11 * size}, src.reset())
+	assert.Equal(t, input, left.String()+right.String())
+	assert.Equal(t, input[10*size+4:11*size+4], sub.String())
+	src.reset()
+	for _, cut := range []Rope{left, right, sub} {
+		for _, leaf := range cut.leaves() {
+			if _, ok := leaf.(*LazyLeaf); !ok {
+				assert.LessOrEqual(t, leaf.Length(), r.Options().MaxLeafSize)
+			}
+		}
+	}
+	assert.Equal(t, want, edited.String())
+	assert.Len(t, src.reset(), 14)
+	var sb strings.Builder
+	_, err = r.WriteTo(&sb)
+	require.NoError(t, err)
+	assert.Equal(t, input, sb.String())
+	assert.Len(t, src.reset(), 16)
+}
+
+func TestFromReaderAt_BTreeAppend(t *testing.T) {
+	input := strings.Repeat("0123456789abcdef\n", 256)
+	src := &countingReaderAt{r: strings.NewReader(input)}
+	// a short last leaf is not merged with the text appended to it
+	r, err := FromReaderAtWithOptions(src, int64(len(input)), Options{Backend: BackendBTree, LazyLeafSize: 1000})
+	require.NoError(t, err)
+	src.reset()
+	r = r.Append(FromString("end"))
+	assert.Empty(t, src.reset())
+	assert.Equal(t, input+"end", r.String())
+}
+
+func TestFromReaderAt_Invalid(t *testing.T) {
+	input := "caf\xe9 au lait, \xf0\x9f\x98 \xf0\x9f\x98\x80"
+
+	r, err := FromReaderAtWithOptions(strings.NewReader(input), int64(len(input)), Options{LazyLeafSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, FromString(input).String(), r.String())
+	assert.Equal(t, FromString(input).ByteLength(), r.ByteLength())
+	assert.Nil(t, r.InvalidRanges())
+
+	r, err = FromReaderAtWithOptions(strings.NewReader(input), int64(len(input)), Options{LazyLeafSize: 3, Lossless: true})
+	require.NoError(t, err)
+	assert.Equal(t, input, r.String())
+	assert.Equal(t, len(input), r.ByteLength())
+	assert.Equal(t, [][]int{{3, 4}, {14, 17}}, r.InvalidRanges())
+}
+
+func TestFromReaderAt_Errors(t *testing.T) {
+	_, err := FromReaderAt(failingReaderAt{}, 10)
+	require.ErrorIs(t, err, errFailingReaderAt)
+
+	_, err = FromReaderAt(strings.NewReader("abc"), 10)
+	require.ErrorIs(t, err, io.EOF)
+
+	_, err = FromReaderAt(strings.NewReader("abc"), -1)
+	require.Error(t, err)
+
+	r, err := FromReaderAt(failingReaderAt{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "", r.String())
+}
+
+func TestFromFileMapped(t *testing.T) {
+	dir := t.TempDir()
+	// multi-byte runes are cut by the boundaries of both the reads and the leaves
+	input := strings.Repeat("héllo, 世界 😀\n", 10000)
+	path := filepath.Join(dir, "text")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	r, err := FromFileMapped(path)
+	require.NoError(t, err)
+	assert.Equal(t, len([]rune(input)), r.Length())
+	assert.Equal(t, 10000, r.NewLineCount())
+	assert.Equal(t, "héllo, 世界 😀", r.Line(5000).String())
+	s := r.String()
+	sub := r.Sub(10, 20).String()
+
+	// the text must not refer to the mapping once it is unmapped
+	r = nil
+	runtime.GC()
+	runtime.GC()
+	assert.Equal(t, input, s)
+	assert.Equal(t, string([]rune(input)[10:20]), sub)
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	r, err = FromFileMapped(empty)
+	require.NoError(t, err)
+	assert.Equal(t, 0, r.Length())
+
+	_, err = FromFileMapped(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFromFileMappedWithOptions(t *testing.T) {
+	input := "caf\xe9\n" + strings.Repeat("x", 1000)
+	path := filepath.Join(t.TempDir(), "text")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	r, err := FromFileMappedWithOptions(path, Options{Backend: BackendBTree, LazyLeafSize: 100, Lossless: true})
+	require.NoError(t, err)
+	assert.Equal(t, input, r.String())
+	assert.Equal(t, [][]int{{3, 4}}, r.InvalidRanges())
+	assert.Equal(t, BackendBTree, r.Options().Backend)
+	assert.Len(t, r.leaves(), 11)
+}
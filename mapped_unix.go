@@ -0,0 +1,42 @@
+//go:build unix
+
+package rope
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fromFileMapped maps a file into memory and scans it into a rope of lazy leaves.
+func fromFileMapped(path string, o *Options) (Rope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	// the mapping remains valid once the file is closed
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		// an empty file cannot be mapped
+		return o.newLeaf(""), nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file of %d bytes is too large to map", size)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping file: %w", err)
+	}
+	src := newSource(bytes.NewReader(data), func() { _ = syscall.Munmap(data) })
+	r, err := fromSource(src, size, o)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return r, nil
+}
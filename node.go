@@ -1,53 +1,77 @@
 package rope
 
+import (
+	"io"
+	"iter"
+	"regexp"
+)
+
 var _ Rope = (*Node)(nil)
 
 type Node struct {
 	left, right Rope
 	weight      int
 	lineWeight  int
+	byteWeight  int
+	utf16Weight int
+	// the totals of the whole tree, so that they can be found without descending it
+	length      int
+	lineBreaks  lineBreaks
+	byteLength  int
+	utf16Length int
+	depth       int
+	opts        *Options
+}
+
+func (n Node) Options() Options {
+	return *n.options()
 }
 
-func newNode(l, r Rope) Rope {
-	return &Node{
-		left:       l,
-		right:      r,
-		weight:     l.Length(),
-		lineWeight: l.NewLineCount(),
+// options returns the options of the node, which are the defaults for the zero value.
+func (n Node) options() *Options {
+	if n.opts == nil {
+		return defaultOptions
 	}
+	return n.opts
 }
 
 func (n Node) String() string {
-	return n.left.String() + n.right.String()
+	return leafText(&n)
 }
 
 func (n Node) Length() int {
-	return n.weight + n.right.Length()
+	return n.length
 }
 
 func (n Node) Append(r Rope) Rope {
-	if n.Length()+r.Length() <= maxLeafSize {
-		return newLeaf(append(n.Data(), r.Data()...))
+	o := n.options()
+	r = o.adopt(r)
+	if r.Length() <= o.MaxLeafSize {
+		// descend the right spine, so that small appends fill the last leaf
+		return n.Insert(n.Length(), r)
 	}
-	return newNode(n, r)
+	return o.autoBalance(o.newNode(&n, r))
 }
 
 func (n Node) Prepend(r Rope) Rope {
-	if n.Length()+r.Length() <= maxLeafSize {
-		return newLeaf(append(r.Data(), n.Data()...))
+	o := n.options()
+	r = o.adopt(r)
+	if r.Length() <= o.MaxLeafSize {
+		// descend the left spine, so that small prepends fill the first leaf
+		return n.Insert(0, r)
 	}
-	return newNode(r, n)
+	return o.autoBalance(o.newNode(r, &n))
 }
 
 func (n Node) Split(at int) (Rope, Rope) {
 	if at < n.weight {
 		// split left
 		left, right := n.left.Split(at)
-		return left, newNode(right, n.right)
+		return left, n.options().newNode(right, n.right)
 	} else if at > n.weight {
 		// split right
 		left, right := n.right.Split(at - n.weight)
-		return newNode(n.left, left), right
+		return n.options().newNode(n.left, left), right
 	} else {
 		// split here
 		return n.left, n.right
@@ -62,7 +86,7 @@ func (n Node) Sub(start, end int) Rope {
 		end = n.Length()
 	}
 	if start >= end {
-		return newLeaf(nil)
+		return n.options().newLeaf("")
 	}
 	if start < n.weight && end < n.weight {
 		// sub left
@@ -74,7 +98,7 @@ func (n Node) Sub(start, end int) Rope {
 		// sub both
 		left := n.left.Sub(start, n.weight)
 		right := n.right.Sub(0, end-n.weight)
-		return newNode(left, right)
+		return n.options().newNode(left, right)
 	}
 }
 
@@ -106,6 +130,13 @@ func (n Node) At(i int) rune {
 }
 
 func (n Node) Line(l int) Rope {
+	if splitsBreak(n.left, n.right) {
+		// the line after the "\r\n" starts after the "\n" which begins the right tree
+		if l < n.lineWeight {
+			return n.left.Line(l)
+		}
+		return n.right.Line(l - n.lineWeight + 1)
+	}
 	if l < n.lineWeight {
 		return n.left.Line(l)
 	} else if l > n.lineWeight {
@@ -115,43 +146,273 @@ func (n Node) Line(l int) Rope {
 }
 
 func (n Node) NewLineCount() int {
-	return n.lineWeight + n.right.NewLineCount()
+	return n.lineBreaks.count()
+}
+
+func (n Node) breaks() lineBreaks {
+	return n.lineBreaks
 }
 
 func (n Node) Depth() int {
-	l := n.left.Depth()
-	r := n.right.Depth()
-	if l >= r {
-		return l + 1
-	}
-	return r + 1
+	return n.depth
 }
 
 func (n Node) Balance() Rope {
-	d := n.Depth()
-	if d < len(fibonacci) && fibonacci[d+2] < n.weight {
-		return n
+	o := n.options()
+	if o.isBalanced(&n) {
+		return &n
 	}
-	leaves := n.leaves()
-	return merge(leaves, 0, len(leaves))
+	return o.rebalance(&n)
 }
 
 func (n Node) leaves() []Rope {
 	return append(n.left.leaves(), n.right.leaves()...)
 }
 
-func merge(leaves []Rope, start, end int) Rope {
+func (n Node) childCount() int {
+	return 2
+}
+
+func (n Node) child(i int) Rope {
+	if i == 0 {
+		return n.left
+	}
+	return n.right
+}
+
+func (o *Options) merge(leaves []Rope, start, end int) Rope {
 	rng := end - start
 	if rng == 1 {
 		return leaves[start]
 	}
 	if rng == 2 {
-		return newNode(leaves[start], leaves[start+1])
+		return o.newNode(leaves[start], leaves[start+1])
 	}
 	mid := start + (rng / 2)
-	return newNode(merge(leaves, start, mid), merge(leaves, mid, end))
+	return o.newNode(o.merge(leaves, start, mid), o.merge(leaves, mid, end))
 }
 
 func (n Node) Data() []rune {
-	return append(n.left.Data(), n.right.Data()...)
+	return treeRunes(&n)
+}
+
+func (n Node) Insert(at int, r Rope) Rope {
+	if at < 0 {
+		at = 0
+	}
+	if at > n.Length() {
+		at = n.Length()
+	}
+	o := n.options()
+	r = o.adopt(r)
+	if at <= n.weight {
+		// insert left
+		return o.autoBalance(o.join(n.left.Insert(at, r), n.right))
+	}
+	// insert right
+	return o.autoBalance(o.join(n.left, n.right.Insert(at-n.weight, r)))
+}
+
+func (n Node) InsertString(at int, s string) Rope {
+	return n.Insert(at, n.options().fromString(s))
+}
+
+func (n Node) Delete(start, end int) Rope {
+	if start < 0 {
+		start = 0
+	}
+	if end > n.Length() {
+		end = n.Length()
+	}
+	if start >= end {
+		return &n
+	}
+	if end <= n.weight {
+		// delete left
+		return n.options().join(n.left.Delete(start, end), n.right)
+	} else if start >= n.weight {
+		// delete right
+		return n.options().join(n.left, n.right.Delete(start-n.weight, end-n.weight))
+	}
+	// delete both
+	return n.options().join(n.left.Delete(start, n.weight), n.right.Delete(0, end-n.weight))
+}
+
+// binaryJoin concatenates two trees, dropping empty sides and collapsing the result into
+// a single leaf when it is small enough to fit in one.
+func (o *Options) binaryJoin(l, r Rope) Rope {
+	if l.Length() == 0 {
+		return r
+	}
+	if r.Length() == 0 {
+		return l
+	}
+	if l.Length()+r.Length() <= o.MaxLeafSize {
+		if merged, ok := o.mergeLeaves(l, r); ok {
+			return merged
+		}
+	}
+	return o.newNode(l, r)
+}
+
+func (n Node) ByteLength() int {
+	return n.byteLength
+}
+
+func (n Node) RuneToByte(i int) int {
+	if i < n.weight {
+		return n.left.RuneToByte(i)
+	}
+	return n.byteWeight + n.right.RuneToByte(i-n.weight)
+}
+
+func (n Node) ByteToRune(b int) int {
+	if b < n.byteWeight {
+		return n.left.ByteToRune(b)
+	}
+	return n.weight + n.right.ByteToRune(b-n.byteWeight)
+}
+
+func (n Node) SplitAtByte(b int) (Rope, Rope) {
+	return n.Split(n.ByteToRune(b))
+}
+
+func (n Node) OffsetOfLine(l int) int {
+	if l < 0 {
+		return -1
+	}
+	split := splitsBreak(n.left, n.right)
+	if l < n.lineWeight || (l == n.lineWeight && !split) {
+		return n.left.OffsetOfLine(l)
+	}
+	if split {
+		l++
+	}
+	if offset := n.right.OffsetOfLine(l - n.lineWeight); offset >= 0 {
+		return n.weight + offset
+	}
+	return -1
+}
+
+func (n Node) LineColumn(i int) (int, int) {
+	if i < n.weight {
+		return n.left.LineColumn(i)
+	}
+	line, col := n.right.LineColumn(i - n.weight)
+	if splitsBreak(n.left, n.right) {
+		if line == 0 {
+			// the "\n" of a "\r\n" is on the same line as the "\r"
+			line, col := n.left.LineColumn(n.weight - 1)
+			return line, col + 1
+		}
+		return n.lineWeight + line - 1, col
+	}
+	if line > 0 {
+		return n.lineWeight + line, col
+	}
+	// the line started in the left tree
+	_, leftCol := n.left.LineColumn(n.weight)
+	return n.lineWeight, leftCol + col
+}
+
+func (n Node) Offset(line, col int) int {
+	return offset(&n, line, col)
+}
+
+func (n Node) UTF16Length() int {
+	return n.utf16Length
+}
+
+func (n Node) RuneToUTF16(i int) int {
+	if i < n.weight {
+		return n.left.RuneToUTF16(i)
+	}
+	return n.utf16Weight + n.right.RuneToUTF16(i-n.weight)
+}
+
+func (n Node) UTF16ToRune(u int) int {
+	if u < n.utf16Weight {
+		return n.left.UTF16ToRune(u)
+	}
+	return n.weight + n.right.UTF16ToRune(u-n.utf16Weight)
+}
+
+func (n Node) WriteTo(w io.Writer) (int64, error) {
+	return writeTo(&n, w)
+}
+
+func (n Node) IterAt(i int) *Iterator {
+	return newIterator(&n, i)
+}
+
+func (n Node) Runes() iter.Seq2[int, rune] {
+	return runesFrom(&n, 0)
+}
+
+func (n Node) RunesFrom(i int) iter.Seq2[int, rune] {
+	return runesFrom(&n, i)
+}
+
+func (n Node) Lines(from, to int) *LineIterator {
+	return newLineIterator(&n, from, to)
+}
+
+func (n Node) Chunks() iter.Seq[string] {
+	return textInRange(&n, 0, n.Length())
+}
+
+func (n Node) ChunksInRange(start, end int) iter.Seq[string] {
+	return textInRange(&n, start, end)
+}
+
+func (n Node) IndexString(s string) int {
+	return indexFrom(&n, s, 0)
+}
+
+func (n Node) IndexFrom(s string, from int) int {
+	return indexFrom(&n, s, from)
+}
+
+func (n Node) LastIndexString(s string) int {
+	return lastIndex(&n, s)
+}
+
+func (n Node) Count(s string) int {
+	return count(&n, s)
+}
+
+func (n Node) FindRegexp(re *regexp.Regexp, from int) []int {
+	return findRegexp(&n, re, from)
+}
+
+func (n Node) FindAllRegexp(re *regexp.Regexp, limit int) [][]int {
+	return findAllRegexp(&n, re, limit)
+}
+
+func (n Node) InvalidRanges() [][]int {
+	return invalidRanges(&n)
+}
+
+func (n Node) LineEnding() LineEnding {
+	return lineEnding(&n)
+}
+
+func (n Node) LineEndings() LineEndingReport {
+	return lineEndings(&n)
+}
+
+func (n Node) NormalizeLineEndings(e LineEnding) Rope {
+	return normalizeLineEndings(&n, e)
+}
+
+func (n Node) Replace(old, new string, limit int) (Rope, []Edit) {
+	return replace(&n, old, new, limit)
+}
+
+func (n Node) ReplaceAll(old, new string) (Rope, []Edit) {
+	return replace(&n, old, new, -1)
+}
+
+func (n Node) ReplaceRegexp(re *regexp.Regexp, template string) (Rope, []Edit) {
+	return replaceRegexp(&n, re, template)
 }
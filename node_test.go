@@ -1,12 +1,19 @@
 package rope
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// newNode joins two trees with the default options.
+func newNode(l, r Rope) Rope {
+	return defaultOptions.newNode(l, r)
+}
+
 func TestNode_Append(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -139,7 +146,7 @@ func TestNode_Balance(t *testing.T) {
 			name:      "empty",
 			node:      newNode(FromString(""), FromString("")),
 			wantValue: "",
-			wantDepth: 2,
+			wantDepth: 1,
 		},
 		{
 			name: "left",
@@ -166,7 +173,31 @@ func TestNode_Balance(t *testing.T) {
 				FromString("xyz"),
 			),
 			wantValue: "abcdefghijklmnopqruvwxyz",
-			wantDepth: 4,
+			wantDepth: 1,
+		},
+		{
+			name:      "balanced",
+			node:      newNode(FromString(strings.Repeat("a", maxLeafSize)), FromString(strings.Repeat("b", maxLeafSize))),
+			wantValue: strings.Repeat("a", maxLeafSize) + strings.Repeat("b", maxLeafSize),
+			wantDepth: 2,
+		},
+		{
+			name:      "left spine of full leaves",
+			node:      testSpine(16, false),
+			wantValue: strings.Repeat("a", 16*maxLeafSize),
+			wantDepth: 6,
+		},
+		{
+			name:      "right spine of full leaves",
+			node:      testSpine(16, true),
+			wantValue: strings.Repeat("a", 16*maxLeafSize),
+			wantDepth: 6,
+		},
+		{
+			name:      "deeper than fibonacci table",
+			node:      testSpine(50, false),
+			wantValue: strings.Repeat("a", 50*maxLeafSize),
+			wantDepth: 8,
 		},
 	}
 	for _, tt := range tests {
@@ -178,6 +209,100 @@ func TestNode_Balance(t *testing.T) {
 	}
 }
 
+// testSpine builds a tree of full leaves in which every node has a leaf on one side.
+func testSpine(leaves int, right bool) Rope {
+	leaf := FromString(strings.Repeat("a", maxLeafSize))
+	r := leaf
+	for i := 1; i < leaves; i++ {
+		if right {
+			r = newNode(leaf, r)
+		} else {
+			r = newNode(r, leaf)
+		}
+	}
+	return r
+}
+
+func TestNode_ByteLength(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			want: 0,
+		},
+		{
+			name: "ascii",
+			node: newNode(FromString("abc"), FromString("def")),
+			want: 6,
+		},
+		{
+			name: "multi-byte",
+			node: newNode(FromString("aé"), FromString("€😀").Append(FromString("b"))),
+			want: 11,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.node.ByteLength())
+		})
+	}
+}
+
+func TestNode_ByteToRune(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "left",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   2,
+			want: 1,
+		},
+		{
+			name: "first right",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   3,
+			want: 2,
+		},
+		{
+			name: "inside right",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   8,
+			want: 3,
+		},
+		{
+			name: "after end",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   11,
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.ByteToRune(tt.at), "ByteToRune(%v)", tt.at)
+		})
+	}
+}
+
 func TestNode_Data(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -218,6 +343,79 @@ func TestNode_Data(t *testing.T) {
 	}
 }
 
+func TestNode_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		node       Rope
+		start, end int
+		want       string
+	}{
+		{
+			name:  "empty",
+			node:  newNode(FromString(""), FromString("")),
+			start: 0,
+			end:   1,
+			want:  "",
+		},
+		{
+			name:  "left",
+			node:  newNode(FromString("abc"), FromString("def")),
+			start: 0,
+			end:   2,
+			want:  "cdef",
+		},
+		{
+			name:  "right",
+			node:  newNode(FromString("abc"), FromString("def")),
+			start: 4,
+			end:   6,
+			want:  "abcd",
+		},
+		{
+			name:  "both",
+			node:  newNode(FromString("abc"), FromString("def")),
+			start: 2,
+			end:   4,
+			want:  "abef",
+		},
+		{
+			name:  "everything",
+			node:  newNode(FromString("abc"), FromString("def")),
+			start: -1,
+			end:   7,
+			want:  "",
+		},
+		{
+			name:  "end < start",
+			node:  newNode(FromString("abc"), FromString("def")),
+			start: 4,
+			end:   1,
+			want:  "abcdef",
+		},
+		{
+			name:  "leaf and branch",
+			node:  newNode(FromString("abc"), FromString("def").Append(FromString("ghi"))),
+			start: 1,
+			end:   7,
+			want:  "ahi",
+		},
+		{
+			name:  "oversize",
+			node:  newNode(FromString(strings.Repeat("a", maxLeafSize)), FromString(strings.Repeat("b", maxLeafSize))),
+			start: 1,
+			end:   maxLeafSize*2 - 1,
+			want:  "ab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.node.String()
+			assert.Equal(t, tt.want, tt.node.Delete(tt.start, tt.end).String())
+			assert.Equal(t, before, tt.node.String())
+		})
+	}
+}
+
 func TestNode_Depth(t *testing.T) {
 	tests := []struct {
 		name string
@@ -324,6 +522,87 @@ func TestNode_Index(t *testing.T) {
 	}
 }
 
+func TestNode_Insert(t *testing.T) {
+	tests := []struct {
+		name   string
+		node   Rope
+		at     int
+		insert string
+		want   string
+	}{
+		{
+			name:   "empty",
+			node:   newNode(FromString(""), FromString("")),
+			at:     0,
+			insert: "abc",
+			want:   "abc",
+		},
+		{
+			name:   "start",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     0,
+			insert: "xyz",
+			want:   "xyzabcdef",
+		},
+		{
+			name:   "left",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     1,
+			insert: "xyz",
+			want:   "axyzbcdef",
+		},
+		{
+			name:   "boundary",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     3,
+			insert: "xyz",
+			want:   "abcxyzdef",
+		},
+		{
+			name:   "right",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     5,
+			insert: "xyz",
+			want:   "abcdexyzf",
+		},
+		{
+			name:   "end",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     6,
+			insert: "xyz",
+			want:   "abcdefxyz",
+		},
+		{
+			name:   "after end",
+			node:   newNode(FromString("abc"), FromString("def")),
+			at:     7,
+			insert: "xyz",
+			want:   "abcdefxyz",
+		},
+		{
+			name:   "leaf and branch",
+			node:   newNode(FromString("abc"), FromString("def").Append(FromString("ghi"))),
+			at:     7,
+			insert: "xyz",
+			want:   "abcdefgxyzhi",
+		},
+		{
+			name:   "oversize",
+			node:   newNode(FromString(strings.Repeat("a", maxLeafSize)), FromString(strings.Repeat("b", maxLeafSize))),
+			at:     maxLeafSize + 1,
+			insert: "c",
+			want:   strings.Repeat("a", maxLeafSize) + "b" + "c" + strings.Repeat("b", maxLeafSize-1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.node.String()
+			assert.Equal(t, tt.want, tt.node.InsertString(tt.at, tt.insert).String())
+			assert.Equal(t, before, tt.node.String())
+		})
+	}
+}
+
 func TestNode_LastIndex(t *testing.T) {
 	tests := []struct {
 		name string
@@ -471,6 +750,59 @@ func TestNode_Line(t *testing.T) {
 	}
 }
 
+func TestNode_LineColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     Rope
+		at       int
+		wantLine int
+		wantCol  int
+	}{
+		{
+			name:     "empty",
+			node:     newNode(FromString(""), FromString("")),
+			at:       0,
+			wantLine: 0,
+			wantCol:  0,
+		},
+		{
+			name:     "left",
+			node:     newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			at:       5,
+			wantLine: 1,
+			wantCol:  1,
+		},
+		{
+			name:     "line spanning both",
+			node:     newNode(FromString("abc\ndef\nghi"), FromString("jkl\nmno")),
+			at:       13,
+			wantLine: 2,
+			wantCol:  5,
+		},
+		{
+			name:     "right",
+			node:     newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			at:       17,
+			wantLine: 4,
+			wantCol:  1,
+		},
+		{
+			name:     "after end",
+			node:     newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			at:       30,
+			wantLine: 4,
+			wantCol:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col := tt.node.LineColumn(tt.at)
+			assert.Equalf(t, tt.wantLine, line, "LineColumn(%v) line", tt.at)
+			assert.Equalf(t, tt.wantCol, col, "LineColumn(%v) column", tt.at)
+		})
+	}
+}
+
 func TestNode_NewLineCount(t *testing.T) {
 	tests := []struct {
 		name string
@@ -505,6 +837,115 @@ func TestNode_NewLineCount(t *testing.T) {
 	}
 }
 
+func TestNode_Offset(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		line int
+		col  int
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			line: 0,
+			col:  0,
+			want: 0,
+		},
+		{
+			name: "left",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 1,
+			col:  2,
+			want: 6,
+		},
+		{
+			name: "line spanning both",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("jkl\nmno")),
+			line: 2,
+			col:  4,
+			want: 12,
+		},
+		{
+			name: "column after end of line",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("jkl\nmno")),
+			line: 2,
+			col:  10,
+			want: 14,
+		},
+		{
+			name: "right",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 4,
+			col:  2,
+			want: 18,
+		},
+		{
+			name: "line after end",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 5,
+			col:  0,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.Offset(tt.line, tt.col), "Offset(%v, %v)", tt.line, tt.col)
+		})
+	}
+}
+
+func TestNode_OffsetOfLine(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		line int
+		want int
+	}{
+		{
+			name: "first",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 0,
+			want: 0,
+		},
+		{
+			name: "-1",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: -1,
+			want: -1,
+		},
+		{
+			name: "last of left",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 2,
+			want: 8,
+		},
+		{
+			name: "first of right",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 3,
+			want: 12,
+		},
+		{
+			name: "last",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 4,
+			want: 16,
+		},
+		{
+			name: "after end",
+			node: newNode(FromString("abc\ndef\nghi"), FromString("\njkl\nmno")),
+			line: 5,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.OffsetOfLine(tt.line), "OffsetOfLine(%v)", tt.line)
+		})
+	}
+}
+
 func TestNode_Prepend(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -560,6 +1001,108 @@ func TestNode_Prepend(t *testing.T) {
 	}
 }
 
+func TestNode_RuneToByte(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "negative",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   -1,
+			want: 0,
+		},
+		{
+			name: "left",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   1,
+			want: 1,
+		},
+		{
+			name: "first right",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   2,
+			want: 3,
+		},
+		{
+			name: "last right",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   3,
+			want: 6,
+		},
+		{
+			name: "end",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   4,
+			want: 10,
+		},
+		{
+			name: "after end",
+			node: newNode(FromString("aé"), FromString("€😀")),
+			at:   5,
+			want: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.RuneToByte(tt.at), "RuneToByte(%v)", tt.at)
+		})
+	}
+}
+
+func TestNode_RuneToUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "left",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   1,
+			want: 1,
+		},
+		{
+			name: "first right",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   2,
+			want: 3,
+		},
+		{
+			name: "end",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   4,
+			want: 6,
+		},
+		{
+			name: "after end",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   5,
+			want: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.RuneToUTF16(tt.at), "RuneToUTF16(%v)", tt.at)
+		})
+	}
+}
+
 func TestNode_Split(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -613,6 +1156,52 @@ func TestNode_Split(t *testing.T) {
 	}
 }
 
+func TestNode_SplitAtByte(t *testing.T) {
+	tests := []struct {
+		name  string
+		node  Rope
+		at    int
+		want1 string
+		want2 string
+	}{
+		{
+			name:  "split empty",
+			node:  newNode(FromString(""), FromString("")),
+			at:    0,
+			want1: "",
+			want2: "",
+		},
+		{
+			name:  "split left-leaf",
+			node:  newNode(FromString("aé"), FromString("€😀")),
+			at:    1,
+			want1: "a",
+			want2: "é€😀",
+		},
+		{
+			name:  "split inside right-leaf",
+			node:  newNode(FromString("aé"), FromString("€😀")),
+			at:    7,
+			want1: "aé€",
+			want2: "😀",
+		},
+		{
+			name:  "after end",
+			node:  newNode(FromString("aé"), FromString("€😀")),
+			at:    11,
+			want1: "aé€😀",
+			want2: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got1, got2 := tt.node.SplitAtByte(tt.at)
+			assert.Equal(t, tt.want1, got1.String())
+			assert.Equal(t, tt.want2, got2.String())
+		})
+	}
+}
+
 func TestNode_String(t *testing.T) {
 	tests := []struct {
 		name string
@@ -684,3 +1273,100 @@ func TestNode_Sub(t *testing.T) {
 		})
 	}
 }
+
+func TestNode_UTF16Length(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			want: 0,
+		},
+		{
+			name: "surrogate pairs",
+			node: newNode(FromString("a😀"), FromString("é😀").Append(FromString("b"))),
+			want: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.node.UTF16Length())
+		})
+	}
+}
+
+func TestNode_UTF16ToRune(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		at   int
+		want int
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			at:   0,
+			want: 0,
+		},
+		{
+			name: "inside left surrogate pair",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   2,
+			want: 1,
+		},
+		{
+			name: "first right",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   3,
+			want: 2,
+		},
+		{
+			name: "inside right surrogate pair",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   5,
+			want: 3,
+		},
+		{
+			name: "after end",
+			node: newNode(FromString("a😀"), FromString("é😀")),
+			at:   7,
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.node.UTF16ToRune(tt.at), "UTF16ToRune(%v)", tt.at)
+		})
+	}
+}
+
+func TestNode_WriteTo(t *testing.T) {
+	tests := []struct {
+		name string
+		node Rope
+		want string
+	}{
+		{
+			name: "empty",
+			node: newNode(FromString(""), FromString("")),
+			want: "",
+		},
+		{
+			name: "multi-byte",
+			node: newNode(FromString("héllo "), FromString("€").Append(FromString("😀"))),
+			want: "héllo €😀",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := tt.node.WriteTo(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.want)), n)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package rope
+
+import (
+	"io"
+	"strings"
+)
+
+// Backend selects the structure of the tree which holds a rope.
+type Backend int
+
+const (
+	// BackendBinary holds a rope in a binary tree of Nodes, which is rebalanced when it grows too deep.
+	BackendBinary Backend = iota
+	// BackendBTree holds a rope in a B-tree of BTreeNodes, with every leaf at the same depth.
+	BackendBTree
+)
+
+// Options configures how a rope is divided into leaves and when it is rebalanced. The options are carried by every
+// node and leaf, so all of the ropes derived from one by editing it follow the same policy. Fields which are not
+// positive take their default values.
+type Options struct {
+	// Backend is the structure of the tree. It defaults to BackendBinary.
+	Backend Backend
+	// MaxLeafSize is the maximum number of runes in a leaf. It defaults to 256.
+	MaxLeafSize int
+	// MinLeafSize is the length below which adjacent leaves are coalesced when a rope is rebalanced.
+	// It defaults to half of MaxLeafSize, and is capped at MaxLeafSize.
+	MinLeafSize int
+	// RebalanceDepth is the depth beyond which Append, Prepend and Insert rebalance the rope they return.
+	// It defaults to 32.
+	RebalanceDepth int
+	// MaxChildren is the maximum number of children of a BTreeNode, when the backend is BackendBTree.
+	// It defaults to 16, and is at least 2.
+	MaxChildren int
+	// Lossless keeps invalid UTF-8 in the input verbatim, each invalid byte being a rune of its own which is read
+	// as utf8.RuneError, so that writing the rope reproduces its input exactly. Otherwise each invalid byte is
+	// replaced with utf8.RuneError.
+	Lossless bool
+	// LazyLeafSize is the number of bytes of the source held by each leaf of the ropes created by
+	// FromReaderAtWithOptions and FromFileMappedWithOptions. It defaults to 64 KiB.
+	LazyLeafSize int
+}
+
+// defaultOptions are the options of ropes created without any.
+var defaultOptions = newOptions(Options{})
+
+// newOptions returns a copy of the options with their defaults filled in, which is shared by the nodes and leaves
+// created with them.
+func newOptions(o Options) *Options {
+	if o.MaxLeafSize <= 0 {
+		o.MaxLeafSize = maxLeafSize
+	}
+	if o.MinLeafSize <= 0 {
+		o.MinLeafSize = max(o.MaxLeafSize/2, 1)
+	}
+	o.MinLeafSize = min(o.MinLeafSize, o.MaxLeafSize)
+	if o.RebalanceDepth <= 0 {
+		o.RebalanceDepth = rebalanceDepth
+	}
+	if o.MaxChildren <= 0 {
+		o.MaxChildren = maxChildren
+	}
+	o.MaxChildren = max(o.MaxChildren, 2)
+	if o.LazyLeafSize <= 0 {
+		o.LazyLeafSize = lazyLeafSize
+	}
+	return &o
+}
+
+// FromStringWithOptions creates a rope from a string, divided into leaves according to the options.
+func FromStringWithOptions(s string, o Options) Rope {
+	return newOptions(o).fromString(s)
+}
+
+// FromReaderWithOptions reads a reader into a rope, divided into leaves according to the options.
+func FromReaderWithOptions(r io.Reader, o Options) (Rope, error) {
+	return fromReader(r, newOptions(o))
+}
+
+// FromFileWithOptions reads a file into a rope, divided into leaves according to the options.
+func FromFileWithOptions(path string, o Options) (Rope, error) {
+	return fromFile(path, newOptions(o))
+}
+
+// FromReaderAtWithOptions creates a rope from the first size bytes of a reader, which is read lazily as with
+// FromReaderAt.
+func FromReaderAtWithOptions(r io.ReaderAt, size int64, o Options) (Rope, error) {
+	return fromSource(newSource(r, nil), size, newOptions(o))
+}
+
+// FromFileMappedWithOptions creates a rope from a file which is mapped into memory and read lazily, as with
+// FromFileMapped.
+func FromFileMappedWithOptions(path string, o Options) (Rope, error) {
+	return fromFileMapped(path, newOptions(o))
+}
+
+// fromString creates a tree from a string, cutting it into leaves of at most MaxLeafSize runes.
+func (o *Options) fromString(s string) Rope {
+	b := builder{opts: o}
+	b.addText(s)
+	return b.rope()
+}
+
+// newLeaf creates a leaf holding UTF-8, which may only be invalid if the options are lossless.
+func (o *Options) newLeaf(data string) Rope {
+	l := &Leaf{
+		data: data,
+		opts: o,
+	}
+	for _, r := range data {
+		l.length++
+		l.utf16Length += utf16Len(r)
+	}
+	l.lineBreaks = countBreaks(data).breakCounts
+	return l
+}
+
+func (o *Options) newNode(l, r Rope) Rope {
+	n := &Node{
+		left:        l,
+		right:       r,
+		weight:      l.Length(),
+		lineWeight:  l.NewLineCount(),
+		byteWeight:  l.ByteLength(),
+		utf16Weight: l.UTF16Length(),
+		depth:       max(l.Depth(), r.Depth()) + 1,
+		opts:        o,
+	}
+	n.length = n.weight + r.Length()
+	n.lineBreaks = joinBreaks(l, r)
+	n.byteLength = n.byteWeight + r.ByteLength()
+	n.utf16Length = n.utf16Weight + r.UTF16Length()
+	return n
+}
+
+// mergeLeaves returns a single leaf holding the text of the trees, or false if it cannot be held in one leaf
+// without changing its runes.
+func (o *Options) mergeLeaves(trees ...Rope) (Rope, bool) {
+	var sb strings.Builder
+	var size int
+	for _, t := range trees {
+		size += t.ByteLength()
+	}
+	sb.Grow(size)
+	for _, t := range trees {
+		if !appendLeafText(&sb, t) {
+			return nil, false
+		}
+	}
+	return o.newLeaf(sb.String()), true
+}
+
+// appendLeafText appends the text of the leaves of a tree, returning false if any of it cannot follow the text
+// before it in a single leaf.
+func appendLeafText(sb *strings.Builder, r Rope) bool {
+	count := r.childCount()
+	if count == 0 {
+		if !joinable(sb.String(), r.String()) {
+			return false
+		}
+		sb.WriteString(r.String())
+		return true
+	}
+	for i := 0; i < count; i++ {
+		if !appendLeafText(sb, r.child(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// join concatenates two trees, dropping empty sides.
+func (o *Options) join(l, r Rope) Rope {
+	if o.Backend == BackendBTree {
+		return o.btreeJoin(l, r)
+	}
+	return o.binaryJoin(l, r)
+}
+
+// build assembles leaves into a balanced tree.
+func (o *Options) build(leaves []Rope) Rope {
+	if len(leaves) == 0 {
+		return o.newLeaf("")
+	}
+	if o.Backend == BackendBTree {
+		return o.btreeBuild(leaves)
+	}
+	return o.merge(leaves, 0, len(leaves))
+}
+
+// adopt returns a tree with the options, rebuilding it if it was created with different ones, so that ropes
+// inserted into a tree follow its policy.
+func (o *Options) adopt(r Rope) Rope {
+	if *r.options() == *o {
+		return r
+	}
+	b := builder{opts: o}
+	for _, leaf := range r.leaves() {
+		b.addText(leaf.String())
+	}
+	return b.rope()
+}
@@ -0,0 +1,165 @@
+package rope
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requireLeavesFit checks that every node and leaf of a tree has the options, and no leaf exceeds MaxLeafSize.
+func requireLeavesFit(t *testing.T, r Rope, o Options, msgAndArgs ...interface{}) {
+	t.Helper()
+	require.Equal(t, o, r.Options(), msgAndArgs...)
+	if r.childCount() == 0 {
+		require.LessOrEqual(t, r.Length(), o.MaxLeafSize, msgAndArgs...)
+		return
+	}
+	for i := 0; i < r.childCount(); i++ {
+		requireLeavesFit(t, r.child(i), o, msgAndArgs...)
+	}
+}
+
+func TestOptions_Defaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    Options
+	}{
+		{
+			name:    "zero",
+			options: Options{},
+			want:    Options{MaxLeafSize: 256, MinLeafSize: 128, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536},
+		},
+		{
+			name:    "max leaf size",
+			options: Options{MaxLeafSize: 1024},
+			want:    Options{MaxLeafSize: 1024, MinLeafSize: 512, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536},
+		},
+		{
+			name:    "tiny leaves",
+			options: Options{MaxLeafSize: 1},
+			want:    Options{MaxLeafSize: 1, MinLeafSize: 1, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536},
+		},
+		{
+			name:    "min leaf size capped",
+			options: Options{MaxLeafSize: 16, MinLeafSize: 64, RebalanceDepth: 8, MaxChildren: 16},
+			want:    Options{MaxLeafSize: 16, MinLeafSize: 16, RebalanceDepth: 8, MaxChildren: 16, LazyLeafSize: 65536},
+		},
+		{
+			name:    "b-tree",
+			options: Options{Backend: BackendBTree, MaxChildren: 1},
+			want:    Options{Backend: BackendBTree, MaxLeafSize: 256, MinLeafSize: 128, RebalanceDepth: 32, MaxChildren: 2, LazyLeafSize: 65536},
+		},
+		{
+			name:    "negative",
+			options: Options{MaxLeafSize: -1, MinLeafSize: -1, RebalanceDepth: -1, MaxChildren: -1},
+			want:    Options{MaxLeafSize: 256, MinLeafSize: 128, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FromStringWithOptions("abc", tt.options).Options())
+		})
+	}
+	assert.Equal(t, Options{MaxLeafSize: 256, MinLeafSize: 128, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536}, FromString("abc").Options())
+	assert.Equal(t, Options{MaxLeafSize: 256, MinLeafSize: 128, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536}, Leaf{}.Options())
+}
+
+func TestFromStringWithOptions(t *testing.T) {
+	s := strings.Repeat("héllo, wörld 😀\n", 100)
+	r := FromStringWithOptions(s, Options{MaxLeafSize: 10})
+	assert.Equal(t, s, r.String())
+	assert.Len(t, r.leaves(), 150)
+	requireLeavesFit(t, r, Options{MaxLeafSize: 10, MinLeafSize: 5, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536})
+}
+
+func TestFromReaderWithOptions(t *testing.T) {
+	s := strings.Repeat("héllo, wörld 😀\n", 100)
+	r, err := FromReaderWithOptions(strings.NewReader(s), Options{MaxLeafSize: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, s, r.String())
+	assert.Len(t, r.leaves(), 2)
+	requireLeavesFit(t, r, Options{MaxLeafSize: 1000, MinLeafSize: 500, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536})
+}
+
+func TestFromFileWithOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("abc\ndef\n"), 0o600))
+	r, err := FromFileWithOptions(path, Options{MaxLeafSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "abc\ndef\n", r.String())
+	requireLeavesFit(t, r, Options{MaxLeafSize: 2, MinLeafSize: 1, RebalanceDepth: 32, MaxChildren: 16, LazyLeafSize: 65536})
+
+	_, err = FromFileWithOptions(filepath.Join(t.TempDir(), "missing.txt"), Options{})
+	assert.Error(t, err)
+}
+
+func TestOptions_RebalanceDepth(t *testing.T) {
+	tests := []struct {
+		name      string
+		depth     int
+		wantDepth int
+	}{
+		{
+			name:      "shallow",
+			depth:     8,
+			wantDepth: 12,
+		},
+		{
+			name:      "deep",
+			depth:     1_000,
+			wantDepth: 500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := FromStringWithOptions("", Options{MaxLeafSize: 4, RebalanceDepth: tt.depth})
+			for i := 0; i < 2_000; i++ {
+				r = r.Append(FromRune('a'))
+			}
+			assert.Equal(t, strings.Repeat("a", 2_000), r.String())
+			assert.Equal(t, tt.wantDepth, r.Depth())
+		})
+	}
+}
+
+func TestOptions_Derived(t *testing.T) {
+	for _, want := range []Options{
+		{Backend: BackendBinary, MaxLeafSize: 8, MinLeafSize: 3, RebalanceDepth: 6, MaxChildren: 16, LazyLeafSize: 65536},
+		{Backend: BackendBTree, MaxLeafSize: 8, MinLeafSize: 3, RebalanceDepth: 6, MaxChildren: 4, LazyLeafSize: 65536},
+	} {
+		for seed := int64(0); seed < 10; seed++ {
+			testOptionsDerived(t, want, seed)
+		}
+	}
+}
+
+func testOptionsDerived(t *testing.T, want Options, seed int64) {
+	t.Run(fmt.Sprintf("backend %d seed %d", want.Backend, seed), func(t *testing.T) {
+		rng := rand.New(rand.NewSource(seed))
+		text := func() string {
+			runes := make([]rune, rng.Intn(40))
+			for i := range runes {
+				runes[i] = []rune("ab€😀\n")[rng.Intn(5)]
+			}
+			return string(runes)
+		}
+
+		v := version{rope: FromStringWithOptions(text(), want)}
+		v.want = []rune(v.rope.String())
+		var ops []string
+		for i := 0; i < 300; i++ {
+			var op string
+			v, op = randomEdit(rng, v, text)
+			ops = append(ops, op)
+			require.Equalf(t, string(v.want), v.rope.String(), "after %v", ops)
+			requireLeavesFit(t, v.rope, want, "after %v", ops)
+		}
+	})
+}
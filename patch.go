@@ -0,0 +1,313 @@
+package rope
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// noNewLine marks a line of a unified diff which is not followed by a new line.
+const noNewLine = "\\ No newline at end of file\n"
+
+// ErrInvalidPatch is returned when a patch is not in the unified diff format.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// PatchConflictError is returned by ApplyPatch when the context or removed lines of a hunk do not match the
+// rope it is applied to. Hunk and Line are zero-based, and Want and Got include any trailing new line.
+type PatchConflictError struct {
+	Hunk int    // the index of the hunk within the patch
+	Line int    // the line of the rope which does not match
+	Want string // the line expected by the patch
+	Got  string // the line found in the rope, or an empty string if the rope has too few lines
+}
+
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("patch hunk %d does not match line %d: want %q, got %q", e.Hunk+1, e.Line+1, e.Want, e.Got)
+}
+
+// UnifiedDiff returns the differences between two trees in the unified diff format, with up to contextLines
+// unchanged lines around each change. An empty string is returned if the trees are equal.
+func UnifiedDiff(a, b Rope, contextLines int) string {
+	_, hunks := Diff(a, b)
+	if len(hunks) == 0 {
+		return ""
+	}
+	contextLines = max(contextLines, 0)
+	oldCount := lineCount(a)
+
+	var sb strings.Builder
+	sb.WriteString("--- a\n+++ b\n")
+	for i := 0; i < len(hunks); {
+		// merge the changes whose context would touch or overlap into one hunk
+		j := i + 1
+		for j < len(hunks) && hunks[j].OldLine-(hunks[j-1].OldLine+hunks[j-1].OldLines) <= 2*contextLines {
+			j++
+		}
+		first, last := hunks[i], hunks[j-1]
+		oldStart := max(first.OldLine-contextLines, 0)
+		newStart := first.NewLine - (first.OldLine - oldStart)
+		oldEnd := min(last.OldLine+last.OldLines+contextLines, oldCount)
+		newEnd := last.NewLine + last.NewLines + oldEnd - (last.OldLine + last.OldLines)
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			formatRange(oldStart, oldEnd-oldStart), formatRange(newStart, newEnd-newStart))
+
+		line := oldStart
+		for _, h := range hunks[i:j] {
+			for ; line < h.OldLine; line++ {
+				writeDiffLine(&sb, ' ', a, line)
+			}
+			for k := h.OldLine; k < h.OldLine+h.OldLines; k++ {
+				writeDiffLine(&sb, '-', a, k)
+			}
+			for k := h.NewLine; k < h.NewLine+h.NewLines; k++ {
+				writeDiffLine(&sb, '+', b, k)
+			}
+			line = h.OldLine + h.OldLines
+		}
+		for ; line < oldEnd; line++ {
+			writeDiffLine(&sb, ' ', a, line)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// formatRange formats the zero-based start and count of a range of lines for a hunk header. Following diff, an
+// empty range is identified by the line before it, and the count of a single line is omitted.
+func formatRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return strconv.Itoa(start + 1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// writeDiffLine writes a line of a tree with the given prefix, marking it if it does not end with a new line. A line
+// ending with a "\r" which is not followed by "\n" is marked too, so that its line break is kept.
+func writeDiffLine(sb *strings.Builder, prefix byte, r Rope, line int) {
+	text := lineText(r, line)
+	sb.WriteByte(prefix)
+	sb.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteByte('\n')
+		sb.WriteString(noNewLine)
+	}
+}
+
+// lineCount returns the number of lines in a tree, not counting an empty line after a trailing line break.
+func lineCount(r Rope) int {
+	count := r.NewLineCount()
+	if n := r.Length(); n > 0 && r.At(n-1) != '\n' && r.At(n-1) != '\r' {
+		count++
+	}
+	return count
+}
+
+// lineText returns a line of a tree, including its trailing line break if it has one.
+func lineText(r Rope, line int) string {
+	return r.Sub(lineOffset(r, line), lineOffset(r, line+1)).String()
+}
+
+// ApplyPatch applies a patch in the unified diff format to a tree, returning the new tree. Any file headers are
+// ignored, so the patch should describe a single file. The context and removed lines of every hunk must match the
+// tree exactly, otherwise a *PatchConflictError is returned and the tree is left unchanged.
+func ApplyPatch(r Rope, patch io.Reader) (Rope, error) {
+	hunks, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	count := lineCount(r)
+	edits := make([]Edit, 0, len(hunks))
+	var next int // the first line after the previous hunk
+	for i, h := range hunks {
+		if h.start < next {
+			return nil, fmt.Errorf("hunk %d overlaps the previous hunk: %w", i+1, ErrInvalidPatch)
+		}
+		for k, want := range h.old {
+			var got string
+			if h.start+k < count {
+				got = lineText(r, h.start+k)
+			}
+			if got != want {
+				return nil, &PatchConflictError{
+					Hunk: i,
+					Line: h.start + k,
+					Want: want,
+					Got:  got,
+				}
+			}
+		}
+		end := h.start + len(h.old)
+		edits = append(edits, Edit{
+			Start: lineOffset(r, h.start),
+			End:   lineOffset(r, end),
+			Text:  strings.Join(h.new, ""),
+		})
+		next = end
+	}
+	return applyEdits(r, edits), nil
+}
+
+// lineOffset returns the index of the first rune of a line, or the length of the tree if there is no such line.
+func lineOffset(r Rope, line int) int {
+	if offset := r.OffsetOfLine(line); offset >= 0 {
+		return offset
+	}
+	return r.Length()
+}
+
+// patchHunk is a parsed hunk of a unified diff. Its lines include their trailing new lines.
+type patchHunk struct {
+	start int // the zero-based line of the first old line
+	old   []string
+	new   []string
+}
+
+// parsePatch reads the hunks of a unified diff.
+func parsePatch(patch io.Reader) ([]patchHunk, error) {
+	br := bufio.NewReader(patch)
+	var p patchParser
+	for number := 1; ; number++ {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read patch: %w", err)
+		}
+		if line == "" {
+			break
+		}
+		content := strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(content, "\\"):
+			if !p.noNewLine() {
+				return nil, fmt.Errorf("line %d: unexpected marker: %w", number, ErrInvalidPatch)
+			}
+		case p.oldLeft == 0 && p.newLeft == 0:
+			p.lastOld, p.lastNew = false, false
+			if !strings.HasPrefix(content, "@@") {
+				// headers and other text between hunks are ignored
+				continue
+			}
+			start, oldCount, newCount, ok := parseHunkHeader(content)
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q: %w", number, content, ErrInvalidPatch)
+			}
+			p.hunks = append(p.hunks, patchHunk{start: start})
+			p.oldLeft, p.newLeft = oldCount, newCount
+		default:
+			if !p.hunkLine(content) {
+				return nil, fmt.Errorf("line %d: unexpected line %q: %w", number, content, ErrInvalidPatch)
+			}
+		}
+	}
+	if p.oldLeft > 0 || p.newLeft > 0 {
+		return nil, fmt.Errorf("patch ends within a hunk: %w", ErrInvalidPatch)
+	}
+	return p.hunks, nil
+}
+
+// patchParser holds the hunks of a unified diff as they are read.
+type patchParser struct {
+	hunks            []patchHunk
+	oldLeft, newLeft int  // the number of old and new lines still to be read in the last hunk
+	lastOld, lastNew bool // whether the last line read was old, new or both
+}
+
+// noNewLine handles a marker that the previous line has no trailing new line, returning false if there is no
+// previous line.
+func (p *patchParser) noNewLine() bool {
+	if len(p.hunks) == 0 || (!p.lastOld && !p.lastNew) {
+		return false
+	}
+	h := &p.hunks[len(p.hunks)-1]
+	if p.lastOld {
+		h.old[len(h.old)-1] = strings.TrimSuffix(h.old[len(h.old)-1], "\n")
+	}
+	if p.lastNew {
+		h.new[len(h.new)-1] = strings.TrimSuffix(h.new[len(h.new)-1], "\n")
+	}
+	p.lastOld, p.lastNew = false, false
+	return true
+}
+
+// hunkLine adds a context, deleted or inserted line to the last hunk, returning false if the line is not one of
+// these or the hunk has no room for it.
+func (p *patchParser) hunkLine(content string) bool {
+	h := &p.hunks[len(p.hunks)-1]
+	text := "\n"
+	kind := byte(' ')
+	if content != "" {
+		// some tools strip the trailing space of an empty context line
+		kind, text = content[0], content[1:]+"\n"
+	}
+	p.lastOld = kind != '+'
+	p.lastNew = kind != '-'
+	if (p.lastOld && p.oldLeft == 0) || (p.lastNew && p.newLeft == 0) || (kind != ' ' && kind != '-' && kind != '+') {
+		return false
+	}
+	if p.lastOld {
+		h.old = append(h.old, text)
+		p.oldLeft--
+	}
+	if p.lastNew {
+		h.new = append(h.new, text)
+		p.newLeft--
+	}
+	return true
+}
+
+// parseHunkHeader parses a hunk header of the form "@@ -l,s +l,s @@", returning the zero-based line of the first
+// old line and the number of old and new lines.
+func parseHunkHeader(header string) (int, int, int, bool) {
+	rest, ok := strings.CutPrefix(header, "@@ -")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	ranges, _, ok := strings.Cut(rest, " @@")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " +")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	oldStart, oldCount, ok := parseRange(oldRange)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	_, newCount, ok := parseRange(newRange)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	if oldCount > 0 {
+		if oldStart == 0 {
+			return 0, 0, 0, false
+		}
+		// the range is identified by its first line, rather than the line before it
+		oldStart--
+	}
+	return oldStart, oldCount, newCount, true
+}
+
+// parseRange parses the start and count of a range of lines in a hunk header, where the count defaults to one.
+func parseRange(s string) (int, int, bool) {
+	startText, countText, found := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if !found {
+		return start, 1, true
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 0 {
+		return 0, 0, false
+	}
+	return start, count, true
+}
@@ -0,0 +1,210 @@
+package rope
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name:    "equal",
+			a:       "a\nb\n",
+			b:       "a\nb\n",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "change",
+			a:       "a\nb\nc\nd\ne\nf\ng\n",
+			b:       "a\nb\nc\nD\ne\nf\ng\n",
+			context: 2,
+			want: "--- a\n+++ b\n" +
+				"@@ -2,5 +2,5 @@\n b\n c\n-d\n+D\n e\n f\n",
+		},
+		{
+			name:    "separate hunks",
+			a:       "a\nb\nc\nd\ne\nf\ng\n",
+			b:       "A\nb\nc\nd\ne\nf\nG\n",
+			context: 1,
+			want: "--- a\n+++ b\n" +
+				"@@ -1,2 +1,2 @@\n-a\n+A\n b\n" +
+				"@@ -6,2 +6,2 @@\n f\n-g\n+G\n",
+		},
+		{
+			name:    "merged hunks",
+			a:       "a\nb\nc\nd\ne\n",
+			b:       "A\nb\nc\nd\nE\n",
+			context: 2,
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n-a\n+A\n b\n c\n d\n-e\n+E\n",
+		},
+		{
+			name:    "insert into empty",
+			a:       "",
+			b:       "a\nb\n",
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "delete line",
+			a:       "a\nb\nc\n",
+			b:       "a\nc\n",
+			context: 0,
+			want:    "--- a\n+++ b\n@@ -2 +1,0 @@\n-b\n",
+		},
+		{
+			name:    "no new line at end",
+			a:       "a\nb",
+			b:       "a\nb\n",
+			context: 1,
+			want: "--- a\n+++ b\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, UnifiedDiff(FromString(tt.a), FromString(tt.b), tt.context))
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "git style patch",
+			r:    "package main\n\nfunc main() {\n}\n",
+			patch: "diff --git a/main.go b/main.go\n" +
+				"index 1234567..89abcde 100644\n" +
+				"--- a/main.go\n" +
+				"+++ b/main.go\n" +
+				"@@ -1,4 +1,5 @@ package main\n" +
+				" package main\n" +
+				"\n" +
+				" func main() {\n" +
+				"+\tprintln(\"hello\")\n" +
+				" }\n",
+			want: "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		},
+		{
+			name:  "insert at start",
+			r:     "b\n",
+			patch: "@@ -0,0 +1 @@\n+a\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "remove new line at end",
+			r:     "a\nb\n",
+			patch: "@@ -2 +2 @@\n-b\n+b\n\\ No newline at end of file\n",
+			want:  "a\nb",
+		},
+		{
+			name:  "multi-byte and crlf",
+			r:     "héllo\r\nwörld\r\n",
+			patch: "@@ -2 +2 @@\n-wörld\r\n+wörld 😀\r\n",
+			want:  "héllo\r\nwörld 😀\r\n",
+		},
+		{
+			name:  "empty patch",
+			r:     "abc",
+			patch: "",
+			want:  "abc",
+		},
+		{
+			name:    "context mismatch",
+			r:       "a\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-x\n+y\n c\n",
+			wantErr: &PatchConflictError{Hunk: 0, Line: 1, Want: "x\n", Got: "b\n"},
+		},
+		{
+			name:    "past the end",
+			r:       "a\n",
+			patch:   "@@ -1,2 +1 @@\n a\n-b\n",
+			wantErr: &PatchConflictError{Hunk: 0, Line: 1, Want: "b\n", Got: ""},
+		},
+		{
+			name:    "missing new line",
+			r:       "a\nb",
+			patch:   "@@ -2 +2 @@\n-b\n+c\n",
+			wantErr: &PatchConflictError{Hunk: 0, Line: 1, Want: "b\n", Got: "b"},
+		},
+		{
+			name:    "malformed header",
+			r:       "a\n",
+			patch:   "@@ -x +1 @@\n",
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "truncated hunk",
+			r:       "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n",
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "overlapping hunks",
+			r:       "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n b\n@@ -2 +2 @@\n b\n",
+			wantErr: ErrInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyPatch(FromString(tt.r), strings.NewReader(tt.patch))
+			if tt.wantErr != nil {
+				var conflict *PatchConflictError
+				if errors.As(tt.wantErr, &conflict) {
+					var gotConflict *PatchConflictError
+					require.ErrorAs(t, err, &gotConflict)
+					assert.Equal(t, conflict, gotConflict)
+				} else {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestApplyPatch_UnifiedDiff(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	text := func() string {
+		var sb strings.Builder
+		for i := rng.Intn(20); i > 0; i-- {
+			sb.WriteString([]string{"a", "b", "é", "😀", "\n", "\r\n", "\r"}[rng.Intn(7)])
+		}
+		return sb.String()
+	}
+	for i := 0; i < 300; i++ {
+		a := testSearchRope(text() + text())
+		b := a
+		for j := rng.Intn(4); j >= 0; j-- {
+			start := rng.Intn(b.Length() + 1)
+			b = b.Delete(start, start+rng.Intn(4)).InsertString(start, text())
+		}
+		patch := UnifiedDiff(a, b, rng.Intn(4))
+		name := fmt.Sprintf("%q -> %q\n%s", a.String(), b.String(), patch)
+
+		got, err := ApplyPatch(a, strings.NewReader(patch))
+		require.NoError(t, err, name)
+		require.Equal(t, b.String(), got.String(), name)
+	}
+}
@@ -0,0 +1,80 @@
+package rope
+
+// Encoding is the unit in which the Character of a Position is measured.
+type Encoding int
+
+const (
+	// EncodingUTF16 measures characters in UTF-16 code units. This is the default for the Language Server Protocol.
+	EncodingUTF16 Encoding = iota
+	// EncodingUTF8 measures characters in UTF-8 bytes.
+	EncodingUTF8
+	// EncodingUTF32 measures characters in runes.
+	EncodingUTF32
+)
+
+// Position is a zero-based line and character offset, as used by the Language Server Protocol.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// PositionOf converts a rune index into a Position, measuring the character offset using the given encoding.
+func PositionOf(r Rope, offset int, enc Encoding) Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > r.Length() {
+		offset = r.Length()
+	}
+	line, col := r.LineColumn(offset)
+	return Position{
+		Line:      line,
+		Character: toUnits(r, offset, enc) - toUnits(r, offset-col, enc),
+	}
+}
+
+// OffsetOf converts a Position into a rune index, measuring the character offset using the given encoding.
+// Characters beyond the end of the line are clamped to the end of the line, and -1 is returned if the line
+// does not exist.
+func OffsetOf(r Rope, p Position, enc Encoding) int {
+	if enc == EncodingUTF32 {
+		return r.Offset(p.Line, p.Character)
+	}
+	start := r.OffsetOfLine(p.Line)
+	if start < 0 {
+		return -1
+	}
+	if p.Character < 0 {
+		return start
+	}
+	i := fromUnits(r, toUnits(r, start, enc)+p.Character, enc)
+	return r.Offset(p.Line, i-start)
+}
+
+func toUnits(r Rope, i int, enc Encoding) int {
+	switch enc {
+	case EncodingUTF8:
+		return r.RuneToByte(i)
+	case EncodingUTF32:
+		return i
+	case EncodingUTF16:
+		fallthrough
+	default:
+		// other values are measured in UTF-16 code units, as in the protocol
+		return r.RuneToUTF16(i)
+	}
+}
+
+func fromUnits(r Rope, u int, enc Encoding) int {
+	switch enc {
+	case EncodingUTF8:
+		return r.ByteToRune(u)
+	case EncodingUTF32:
+		return u
+	case EncodingUTF16:
+		fallthrough
+	default:
+		// other values are measured in UTF-16 code units, as in the protocol
+		return r.UTF16ToRune(u)
+	}
+}
@@ -0,0 +1,137 @@
+package rope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PositionOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		rope   Rope
+		offset int
+		enc    Encoding
+		want   Position
+	}{
+		{
+			name:   "empty",
+			rope:   FromString(""),
+			offset: 0,
+			enc:    EncodingUTF16,
+			want:   Position{Line: 0, Character: 0},
+		},
+		{
+			name:   "utf-16 surrogate pair",
+			rope:   FromString("abc\n😀é€x"),
+			offset: 7,
+			enc:    EncodingUTF16,
+			want:   Position{Line: 1, Character: 4},
+		},
+		{
+			name:   "utf-8",
+			rope:   FromString("abc\n😀é€x"),
+			offset: 7,
+			enc:    EncodingUTF8,
+			want:   Position{Line: 1, Character: 9},
+		},
+		{
+			name:   "utf-32",
+			rope:   FromString("abc\n😀é€x"),
+			offset: 7,
+			enc:    EncodingUTF32,
+			want:   Position{Line: 1, Character: 3},
+		},
+		{
+			name:   "across nodes",
+			rope:   newNode(FromString("ab\n😀"), FromString("é\n€x")),
+			offset: 5,
+			enc:    EncodingUTF16,
+			want:   Position{Line: 1, Character: 3},
+		},
+		{
+			name:   "after end",
+			rope:   FromString("abc\n😀"),
+			offset: 10,
+			enc:    EncodingUTF16,
+			want:   Position{Line: 1, Character: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PositionOf(tt.rope, tt.offset, tt.enc))
+		})
+	}
+}
+
+func Test_OffsetOf(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		pos  Position
+		enc  Encoding
+		want int
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			pos:  Position{Line: 0, Character: 0},
+			enc:  EncodingUTF16,
+			want: 0,
+		},
+		{
+			name: "utf-16 surrogate pair",
+			rope: FromString("abc\n😀é€x"),
+			pos:  Position{Line: 1, Character: 4},
+			enc:  EncodingUTF16,
+			want: 7,
+		},
+		{
+			name: "utf-16 inside surrogate pair",
+			rope: FromString("abc\n😀é€x"),
+			pos:  Position{Line: 1, Character: 1},
+			enc:  EncodingUTF16,
+			want: 4,
+		},
+		{
+			name: "utf-8",
+			rope: FromString("abc\n😀é€x"),
+			pos:  Position{Line: 1, Character: 9},
+			enc:  EncodingUTF8,
+			want: 7,
+		},
+		{
+			name: "utf-32",
+			rope: FromString("abc\n😀é€x"),
+			pos:  Position{Line: 1, Character: 3},
+			enc:  EncodingUTF32,
+			want: 7,
+		},
+		{
+			name: "across nodes",
+			rope: newNode(FromString("ab\n😀"), FromString("é\n€x")),
+			pos:  Position{Line: 1, Character: 3},
+			enc:  EncodingUTF16,
+			want: 5,
+		},
+		{
+			name: "character after end of line",
+			rope: FromString("abc\n😀é\n€x"),
+			pos:  Position{Line: 1, Character: 10},
+			enc:  EncodingUTF16,
+			want: 6,
+		},
+		{
+			name: "line after end",
+			rope: FromString("abc\n😀"),
+			pos:  Position{Line: 2, Character: 0},
+			enc:  EncodingUTF16,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, OffsetOf(tt.rope, tt.pos, tt.enc))
+		})
+	}
+}
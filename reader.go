@@ -0,0 +1,164 @@
+package rope
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+var (
+	_ io.Reader      = (*Reader)(nil)
+	_ io.RuneScanner = (*Reader)(nil)
+	_ io.Seeker      = (*Reader)(nil)
+	_ io.WriterTo    = (*Reader)(nil)
+)
+
+// Reader reads the UTF-8 encoding of a rope, walking its leaves in order so that the full string is never allocated.
+// It implements io.Reader, io.RuneScanner, io.Seeker and io.WriterTo.
+type Reader struct {
+	rope     Rope
+	stack    []Rope // subtrees still to be read, with the next on top
+	buf      string // UTF-8 encoding of the current leaf
+	pos      int    // read position in buf
+	base     int64  // byte offset of the start of buf
+	prevRune int    // size of the last rune read, or -1 if the last operation was not a ReadRune
+}
+
+// NewReader creates a Reader positioned at the start of the rope.
+func NewReader(r Rope) *Reader {
+	return &Reader{
+		rope:     r,
+		stack:    []Rope{r},
+		prevRune: -1,
+	}
+}
+
+// next moves to the next leaf, returning false if there are no more leaves.
+func (r *Reader) next() bool {
+	for len(r.stack) > 0 {
+		top := r.stack[len(r.stack)-1]
+		r.stack = r.stack[:len(r.stack)-1]
+		count := top.childCount()
+		if count == 0 {
+			r.base += int64(len(r.buf))
+			r.buf = leafString(top)
+			r.pos = 0
+			return true
+		}
+		for i := count - 1; i >= 0; i-- {
+			r.stack = append(r.stack, top.child(i))
+		}
+	}
+	return false
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	r.prevRune = -1
+	var total int
+	for len(p) > 0 {
+		if r.pos == len(r.buf) && !r.next() {
+			break
+		}
+		n := copy(p, r.buf[r.pos:])
+		r.pos += n
+		total += n
+		p = p[n:]
+	}
+	if total == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return total, nil
+}
+
+// ReadRune implements io.RuneReader.
+func (r *Reader) ReadRune() (rune, int, error) {
+	r.prevRune = -1
+	for r.pos == len(r.buf) {
+		if !r.next() {
+			return 0, 0, io.EOF
+		}
+	}
+	c, size := utf8.DecodeRuneInString(r.buf[r.pos:])
+	r.pos += size
+	r.prevRune = size
+	return c, size, nil
+}
+
+// UnreadRune implements io.RuneScanner.
+func (r *Reader) UnreadRune() error {
+	if r.prevRune < 0 {
+		return errors.New("rope.Reader.UnreadRune: previous operation was not ReadRune")
+	}
+	r.pos -= r.prevRune
+	r.prevRune = -1
+	return nil
+}
+
+// Seek implements io.Seeker. Offsets are measured in bytes of the UTF-8 encoding.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	r.prevRune = -1
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.base + int64(r.pos) + offset
+	case io.SeekEnd:
+		abs = int64(r.rope.ByteLength()) + offset
+	default:
+		return 0, fmt.Errorf("rope.Reader.Seek: invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, errors.New("rope.Reader.Seek: negative position")
+	}
+	r.stack = r.stack[:0]
+	if abs >= int64(r.rope.ByteLength()) {
+		// past the end
+		r.buf = ""
+		r.base = abs
+		r.pos = 0
+		return abs, nil
+	}
+	// descend to the leaf containing the position, keeping the subtrees after it
+	node := r.rope
+	var base int64
+	for count := node.childCount(); count > 0; count = node.childCount() {
+		i := 0
+		for ; i < count-1; i++ {
+			size := int64(node.child(i).ByteLength())
+			if abs < base+size {
+				break
+			}
+			base += size
+		}
+		for j := count - 1; j > i; j-- {
+			r.stack = append(r.stack, node.child(j))
+		}
+		node = node.child(i)
+	}
+	r.buf = leafString(node)
+	r.base = base
+	r.pos = int(abs - base)
+	return abs, nil
+}
+
+// WriteTo implements io.WriterTo, writing the remainder of the rope.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	r.prevRune = -1
+	var total int64
+	for {
+		if r.pos < len(r.buf) {
+			n, err := io.WriteString(w, r.buf[r.pos:])
+			r.pos += n
+			total += int64(n)
+			if err != nil {
+				return total, fmt.Errorf("error writing: %w", err)
+			}
+		}
+		if !r.next() {
+			return total, nil
+		}
+	}
+}
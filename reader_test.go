@@ -0,0 +1,216 @@
+package rope
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		want string
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			want: "",
+		},
+		{
+			name: "leaf",
+			rope: FromString("hello world!"),
+			want: "hello world!",
+		},
+		{
+			name: "node",
+			rope: newNode(FromString("héllo "), newNode(FromString(""), FromString("wörld €😀"))),
+			want: "héllo wörld €😀",
+		},
+		{
+			name: "large",
+			rope: FromString(strings.Repeat("a", maxLeafSize)).Append(FromString(strings.Repeat("€", maxLeafSize*3))),
+			want: strings.Repeat("a", maxLeafSize) + strings.Repeat("€", maxLeafSize*3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, iotest.TestReader(NewReader(tt.rope), []byte(tt.want)))
+		})
+	}
+}
+
+func TestReader_ReadRune(t *testing.T) {
+	r := NewReader(newNode(FromString("aé"), FromString("€😀")))
+
+	var got []rune
+	var sizes []int
+	for {
+		c, size, err := r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, c)
+		sizes = append(sizes, size)
+	}
+	assert.Equal(t, []rune("aé€😀"), got)
+	assert.Equal(t, []int{1, 2, 3, 4}, sizes)
+}
+
+func TestReader_UnreadRune(t *testing.T) {
+	r := NewReader(newNode(FromString("aé"), FromString("€😀")))
+
+	require.Error(t, r.UnreadRune())
+
+	c, _, err := r.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'a', c)
+	c, _, err = r.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'é', c)
+	c, _, err = r.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, '€', c)
+
+	require.NoError(t, r.UnreadRune())
+	require.Error(t, r.UnreadRune())
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "€😀", string(rest))
+}
+
+func TestReader_Seek(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  int64
+		whence  int
+		wantPos int64
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "start",
+			offset:  0,
+			whence:  io.SeekStart,
+			wantPos: 0,
+			want:    "aé€😀",
+		},
+		{
+			name:    "second leaf",
+			offset:  3,
+			whence:  io.SeekStart,
+			wantPos: 3,
+			want:    "€😀",
+		},
+		{
+			name:    "current",
+			offset:  1,
+			whence:  io.SeekCurrent,
+			wantPos: 2,
+			want:    "\xa9€😀",
+		},
+		{
+			name:    "end",
+			offset:  -4,
+			whence:  io.SeekEnd,
+			wantPos: 6,
+			want:    "😀",
+		},
+		{
+			name:    "past end",
+			offset:  20,
+			whence:  io.SeekStart,
+			wantPos: 20,
+			want:    "",
+		},
+		{
+			name:    "negative",
+			offset:  -1,
+			whence:  io.SeekStart,
+			wantErr: true,
+		},
+		{
+			name:    "invalid whence",
+			offset:  0,
+			whence:  3,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(newNode(FromString("aé"), FromString("€😀")))
+			_, _, err := r.ReadRune()
+			require.NoError(t, err)
+
+			pos, err := r.Seek(tt.offset, tt.whence)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPos, pos)
+
+			rest, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(rest))
+		})
+	}
+}
+
+func TestReader_WriteTo(t *testing.T) {
+	r := NewReader(newNode(FromString("hello "), FromString("world!")))
+	buf := make([]byte, 3)
+	_, err := io.ReadFull(r, buf)
+	require.NoError(t, err)
+
+	var w bytes.Buffer
+	n, err := r.WriteTo(&w)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), n)
+	assert.Equal(t, "lo world!", w.String())
+
+	n, err = r.WriteTo(&w)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestReader_SeekLeaves(t *testing.T) {
+	text := strings.Repeat("héllo wörld €😀\n", 50)
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			o := backend.options
+			o.MaxLeafSize = 7
+			r := NewReader(FromStringWithOptions(text, o))
+			for offset := len(text); offset >= 0; offset -= 13 {
+				pos, err := r.Seek(int64(offset), io.SeekStart)
+				require.NoError(t, err)
+				assert.Equal(t, int64(offset), pos)
+				rest, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.Equal(t, text[offset:], string(rest), "offset %d", offset)
+			}
+		})
+	}
+}
+
+func TestRope_WriteTo_Allocations(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			r := FromStringWithOptions(strings.Repeat("héllo wörld\n", 1<<16), backend.options)
+			// the leaves are written as they are found, without gathering them first
+			assert.Less(t, testing.AllocsPerRun(10, func() {
+				_, _ = r.WriteTo(io.Discard)
+			}), float64(10))
+			assert.Less(t, testing.AllocsPerRun(10, func() {
+				_, _ = NewReader(r).WriteTo(io.Discard)
+			}), float64(10))
+		})
+	}
+}
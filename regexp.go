@@ -0,0 +1,96 @@
+package rope
+
+import (
+	"io"
+	"regexp"
+)
+
+// runeReader adapts an Iterator to io.RuneReader. Every rune is reported as a single unit wide,
+// so the indexes returned by regexp are rune offsets rather than byte offsets.
+type runeReader struct {
+	it *Iterator
+}
+
+func (r runeReader) ReadRune() (rune, int, error) {
+	c, ok := r.it.Next()
+	if !ok {
+		return 0, 0, io.EOF
+	}
+	return c, 1, nil
+}
+
+// findRegexp returns the rune indexes of the first match of re in a tree at or after from, or nil if there is none.
+// Matching starts fresh at from, so ^ and \b treat from as the start of the text.
+func findRegexp(r Rope, re *regexp.Regexp, from int) []int {
+	if from < 0 {
+		from = 0
+	}
+	if from > r.Length() {
+		return nil
+	}
+	loc := re.FindReaderSubmatchIndex(runeReader{it: r.IterAt(from)})
+	for i := range loc {
+		if loc[i] >= 0 {
+			loc[i] += from
+		}
+	}
+	return loc
+}
+
+// findAllRegexp returns the rune indexes of up to n successive non-overlapping matches of re in a tree.
+// As with regexp, empty matches abutting a preceding match are ignored, and each search after the first sees the
+// rune before it, so that ^, \A, \b and \B only match where they would in the whole text.
+func findAllRegexp(r Rope, re *regexp.Regexp, n int) [][]int {
+	var matches [][]int
+	var after *regexp.Regexp
+	prevEnd := -1
+	for pos := 0; pos <= r.Length() && (n < 0 || len(matches) < n); {
+		var loc []int
+		if pos == 0 {
+			loc = findRegexp(r, re, pos)
+		} else {
+			if after == nil {
+				after = contextRegexp(re)
+			}
+			loc = findRegexpAfter(r, after, pos)
+		}
+		if loc == nil {
+			break
+		}
+		if loc[1] == loc[0] {
+			// step over the empty match so that the next search makes progress
+			pos = loc[1] + 1
+			if loc[0] == prevEnd {
+				continue
+			}
+		} else {
+			pos = loc[1]
+		}
+		prevEnd = loc[1]
+		matches = append(matches, loc)
+	}
+	return matches
+}
+
+// contextRegexp wraps re so that it can be matched against text preceded by a single rune of context: the wrapper
+// consumes the context rune and any runes before the match, and the match of re is its first submatch. The
+// leftmost-longest mode of Regexp.Longest is not carried over, as it cannot be read from re.
+func contextRegexp(re *regexp.Regexp) *regexp.Regexp {
+	return regexp.MustCompile(`\A(?s:.)(?s:.*?)(` + re.String() + `)`)
+}
+
+// findRegexpAfter returns the rune indexes of the first match of a regexp wrapped by contextRegexp in a tree at or
+// after pos, which must be positive, or nil if there is none.
+func findRegexpAfter(r Rope, re *regexp.Regexp, pos int) []int {
+	loc := re.FindReaderSubmatchIndex(runeReader{it: r.IterAt(pos - 1)})
+	if loc == nil {
+		return nil
+	}
+	loc = loc[2:]
+	for i := range loc {
+		if loc[i] >= 0 {
+			loc[i] += pos - 1
+		}
+	}
+	return loc
+}
@@ -0,0 +1,188 @@
+package rope
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRope_FindRegexp(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		re   string
+		from int
+		want []int
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			re:   "a",
+			from: 0,
+			want: nil,
+		},
+		{
+			name: "leaf",
+			rope: FromString("hello world"),
+			re:   "o w",
+			from: 0,
+			want: []int{4, 7},
+		},
+		{
+			name: "multi-byte",
+			rope: FromString("héllo wörld"),
+			re:   "w.r",
+			from: 0,
+			want: []int{6, 9},
+		},
+		{
+			name: "submatches across leaves",
+			rope: testSearchRope("héllo wörld €42"),
+			re:   `(w(ö)rld) €(\d+)`,
+			from: 0,
+			want: []int{6, 15, 6, 11, 7, 8, 13, 15},
+		},
+		{
+			name: "unmatched submatch",
+			rope: testSearchRope("héllo wörld"),
+			re:   `(x)?wörld`,
+			from: 0,
+			want: []int{6, 11, -1, -1},
+		},
+		{
+			name: "from",
+			rope: testSearchRope("€ab€ab"),
+			re:   "ab",
+			from: 2,
+			want: []int{4, 6},
+		},
+		{
+			name: "from after end",
+			rope: testSearchRope("€ab€ab"),
+			re:   "ab",
+			from: 7,
+			want: nil,
+		},
+		{
+			name: "not found",
+			rope: testSearchRope("hello world"),
+			re:   "[0-9]+",
+			from: 0,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := regexp.MustCompile(tt.re)
+			assert.Equalf(t, tt.want, tt.rope.FindRegexp(re, tt.from), "FindRegexp(%s, %v)", tt.re, tt.from)
+		})
+	}
+}
+
+func TestRope_FindAllRegexp(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		re    string
+		n     int
+	}{
+		{
+			name:  "words",
+			input: "héllo wörld, hello world",
+			re:    `\pL+`,
+			n:     -1,
+		},
+		{
+			name:  "limit",
+			input: "héllo wörld, hello world",
+			re:    `\pL+`,
+			n:     2,
+		},
+		{
+			name:  "submatches",
+			input: "a=1, bé=22, c=€",
+			re:    `(\pL+)=(\d*)`,
+			n:     -1,
+		},
+		{
+			name:  "empty matches",
+			input: "baaacé",
+			re:    `a*`,
+			n:     -1,
+		},
+		{
+			name:  "empty input",
+			input: "",
+			re:    `a*`,
+			n:     -1,
+		},
+		{
+			name:  "start of text",
+			input: "aaa foo",
+			re:    `^a`,
+			n:     -1,
+		},
+		{
+			name:  "absolute start of text",
+			input: "aaa foo",
+			re:    `\Aa|o`,
+			n:     -1,
+		},
+		{
+			name:  "start of line",
+			input: "aé\nab\r\naa",
+			re:    `(?m)^a`,
+			n:     -1,
+		},
+		{
+			name:  "not a word boundary",
+			input: "aaa foo",
+			re:    `\Ba`,
+			n:     -1,
+		},
+		{
+			name:  "word boundary",
+			input: "aaa éa a",
+			re:    `\ba|a\b`,
+			n:     -1,
+		},
+		{
+			name:  "empty matches at boundaries",
+			input: "ab cé",
+			re:    `\b`,
+			n:     -1,
+		},
+		{
+			name:  "end of text",
+			input: "a\na\n",
+			re:    `a$|(?m)a$`,
+			n:     -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := regexp.MustCompile(tt.re)
+			want := re.FindAllStringSubmatchIndex(tt.input, tt.n)
+			for _, loc := range want {
+				for i := range loc {
+					if loc[i] >= 0 {
+						loc[i] = utf8.RuneCountInString(tt.input[:loc[i]])
+					}
+				}
+			}
+			assert.Equal(t, want, testSearchRope(tt.input).FindAllRegexp(re, tt.n))
+		})
+	}
+}
+
+func TestRope_FindAllRegexp_Large(t *testing.T) {
+	r, err := FromReader(strings.NewReader(strings.Repeat("héllo wörld\n", 50_000)))
+	require.NoError(t, err)
+	matches := r.FindAllRegexp(regexp.MustCompile(`w.rld`), -1)
+	assert.Len(t, matches, 50_000)
+	assert.Equal(t, []int{12*49_999 + 6, 12*49_999 + 11}, matches[len(matches)-1])
+}
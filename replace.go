@@ -0,0 +1,57 @@
+package rope
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// replace replaces up to n non-overlapping occurrences of old with new in a tree.
+func replace(r Rope, old, new string, n int) (Rope, []Edit) {
+	if n == 0 {
+		return r, nil
+	}
+	size := utf8.RuneCountInString(old)
+	indexes := indexAll(r, old, n)
+	edits := make([]Edit, 0, len(indexes))
+	for _, i := range indexes {
+		edits = append(edits, Edit{
+			Start: i,
+			End:   i + size,
+			Text:  new,
+		})
+	}
+	return applyEdits(r, edits), edits
+}
+
+// replaceRegexp replaces all matches of re in a tree with the expansion of template.
+func replaceRegexp(r Rope, re *regexp.Regexp, template string) (Rope, []Edit) {
+	matches := r.FindAllRegexp(re, -1)
+	edits := make([]Edit, 0, len(matches))
+	for _, loc := range matches {
+		// expand against the text of the match alone, converting the rune indexes to byte offsets within it
+		src := r.Sub(loc[0], loc[1])
+		offsets := make([]int, len(loc))
+		for i, index := range loc {
+			if index < 0 {
+				offsets[i] = -1
+				continue
+			}
+			offsets[i] = src.RuneToByte(index - loc[0])
+		}
+		edits = append(edits, Edit{
+			Start: loc[0],
+			End:   loc[1],
+			Text:  string(re.ExpandString(nil, template, src.String(), offsets)),
+		})
+	}
+	return applyEdits(r, edits), edits
+}
+
+// applyEdits applies ordered, non-overlapping edits to a tree. The edits are applied from last to first so that
+// their indexes remain valid, and each one only rebuilds the path to the runes it touches.
+func applyEdits(r Rope, edits []Edit) Rope {
+	for i := len(edits) - 1; i >= 0; i-- {
+		r = edits[i].Apply(r)
+	}
+	return r
+}
@@ -0,0 +1,173 @@
+package rope
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRope_Replace(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		old, new  string
+		n         int
+		wantEdits []Edit
+	}{
+		{
+			name:      "none",
+			input:     "hello world",
+			old:       "x",
+			new:       "y",
+			n:         -1,
+			wantEdits: []Edit{},
+		},
+		{
+			name:  "all",
+			input: "héllo wörld, héllo world",
+			old:   "héllo",
+			new:   "bye",
+			n:     -1,
+			wantEdits: []Edit{
+				{Start: 0, End: 5, Text: "bye"},
+				{Start: 13, End: 18, Text: "bye"},
+			},
+		},
+		{
+			name:  "limit",
+			input: "aaaaa",
+			old:   "aa",
+			new:   "€",
+			n:     1,
+			wantEdits: []Edit{
+				{Start: 0, End: 2, Text: "€"},
+			},
+		},
+		{
+			name:      "zero",
+			input:     "aaaaa",
+			old:       "aa",
+			new:       "€",
+			n:         0,
+			wantEdits: nil,
+		},
+		{
+			name:  "empty old",
+			input: "é€",
+			old:   "",
+			new:   "-",
+			n:     -1,
+			wantEdits: []Edit{
+				{Start: 0, End: 0, Text: "-"},
+				{Start: 1, End: 1, Text: "-"},
+				{Start: 2, End: 2, Text: "-"},
+			},
+		},
+		{
+			name:  "delete",
+			input: "a, b, c",
+			old:   ", ",
+			new:   "",
+			n:     -1,
+			wantEdits: []Edit{
+				{Start: 1, End: 3, Text: ""},
+				{Start: 4, End: 6, Text: ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testSearchRope(tt.input)
+			got, edits := r.Replace(tt.old, tt.new, tt.n)
+			assert.Equal(t, strings.Replace(tt.input, tt.old, tt.new, tt.n), got.String())
+			assert.Equal(t, tt.wantEdits, edits)
+			assert.Equal(t, tt.input, r.String())
+		})
+	}
+}
+
+func TestRope_ReplaceAll(t *testing.T) {
+	r := newNode(FromString("one fish, two "), FromString("fish, red fish"))
+	got, edits := r.ReplaceAll("fish", "🐟")
+	assert.Equal(t, "one 🐟, two 🐟, red 🐟", got.String())
+	assert.Len(t, edits, 3)
+}
+
+func TestRope_ReplaceRegexp(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		re       string
+		template string
+	}{
+		{
+			name:     "literal",
+			input:    "héllo wörld",
+			re:       `ö`,
+			template: "o",
+		},
+		{
+			name:     "submatches",
+			input:    "a=1, bé=22, c=€",
+			re:       `(\pL+)=(\d*)`,
+			template: "$2:$1",
+		},
+		{
+			name:     "named submatches",
+			input:    "wörld héllo",
+			re:       `(?P<first>\pL+) (?P<second>\pL+)`,
+			template: "${second} ${first}",
+		},
+		{
+			name:     "unmatched submatch",
+			input:    "ab b",
+			re:       `(a)?b`,
+			template: "[$1]",
+		},
+		{
+			name:     "start of text",
+			input:    "aaa foo",
+			re:       `^a`,
+			template: "X",
+		},
+		{
+			name:     "word boundaries",
+			input:    "aaa foo a",
+			re:       `\Ba|\bf`,
+			template: "X",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := regexp.MustCompile(tt.re)
+			got, edits := testSearchRope(tt.input).ReplaceRegexp(re, tt.template)
+			assert.Equal(t, re.ReplaceAllString(tt.input, tt.template), got.String())
+			assert.Len(t, edits, len(re.FindAllStringIndex(tt.input, -1)))
+		})
+	}
+}
+
+func TestRope_Replace_SharesLeaves(t *testing.T) {
+	r, err := FromReader(strings.NewReader(strings.Repeat("a", maxLeafSize*64) + "needle" + strings.Repeat("b", maxLeafSize*64)))
+	require.NoError(t, err)
+
+	got, edits := r.ReplaceAll("needle", "thread")
+	require.Len(t, edits, 1)
+
+	original := make(map[*byte]struct{})
+	for chunk := range r.Chunks() {
+		original[unsafe.StringData(chunk)] = struct{}{}
+	}
+	var shared, total int
+	for chunk := range got.Chunks() {
+		total++
+		if _, ok := original[unsafe.StringData(chunk)]; ok {
+			shared++
+		}
+	}
+	assert.GreaterOrEqual(t, shared, total-3)
+}
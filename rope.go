@@ -1,12 +1,19 @@
 package rope
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
+// readSize is the number of bytes requested from a reader at a time.
+const readSize = 32 * 1024
+
 // Rope is a data structure for efficiently storing and manipulating UTF-8 text.
 // It is a binary tree where each leaf node contains a string of runes.
 // The length of the string is the sum of the lengths of the strings in the leaf nodes.
@@ -32,11 +39,88 @@ import (
 //
 // Length returns the length of the string.
 //
-// Line returns the line at the given (zero-based) index.
+// Line returns the line at the given (zero-based) index, without its line break. Lines are broken by "\n", "\r\n"
+// or a "\r" which is not followed by "\n".
 //
-// NewLineCount returns the number of new lines in the tree.
+// NewLineCount returns the number of line breaks in the tree, counting each "\r\n" once.
 //
 // Balance the tree, using the leaf size as the maximum length of a leaf node.
+//
+// Insert the provided tree at the given index.
+//
+// InsertString inserts the provided string at the given index.
+//
+// Delete removes the runes between the start and end indexes.
+//
+// ByteLength returns the length of the UTF-8 encoding of the string.
+//
+// RuneToByte converts a rune index into a UTF-8 byte offset.
+//
+// ByteToRune converts a UTF-8 byte offset into the index of the rune containing it.
+//
+// SplitAtByte splits the tree at the rune containing the given UTF-8 byte offset.
+//
+// OffsetOfLine returns the index of the first rune of the given (zero-based) line.
+//
+// LineColumn returns the (zero-based) line and column of the given index.
+//
+// Offset returns the index of the given (zero-based) line and column.
+//
+// UTF16Length returns the length of the UTF-16 encoding of the string, in code units.
+//
+// RuneToUTF16 converts a rune index into a UTF-16 code unit offset.
+//
+// UTF16ToRune converts a UTF-16 code unit offset into the index of the rune containing it.
+//
+// WriteTo writes the UTF-8 encoding of the string to a writer, one leaf at a time.
+//
+// IterAt returns an Iterator positioned before the rune at the given index.
+//
+// Runes returns an iterator over the index and value of each rune.
+//
+// RunesFrom returns an iterator over the index and value of each rune, starting at the given index.
+//
+// Lines returns a LineIterator over the (zero-based) lines in the range [from, to).
+//
+// Chunks returns an iterator over the UTF-8 text stored in each leaf, in order, without copying it.
+//
+// ChunksInRange returns an iterator over the UTF-8 text stored in each leaf, limited to the given start and end
+// indexes, without copying it.
+//
+// IndexString returns the index of the first occurrence of a substring.
+//
+// IndexFrom returns the index of the first occurrence of a substring at or after the given index.
+//
+// LastIndexString returns the index of the last occurrence of a substring.
+//
+// Count returns the number of non-overlapping occurrences of a substring.
+//
+// FindRegexp returns the rune indexes of the first match of a regular expression, and its submatches,
+// at or after the given index.
+//
+// FindAllRegexp returns the rune indexes of up to n successive matches of a regular expression, and their
+// submatches. If n is negative, all matches are returned.
+//
+// Replace replaces the first n non-overlapping occurrences of a substring, returning the new tree and the edits made.
+// If n is negative, all occurrences are replaced.
+//
+// ReplaceAll replaces all non-overlapping occurrences of a substring, returning the new tree and the edits made.
+//
+// ReplaceRegexp replaces all matches of a regular expression with the expansion of a template, as in
+// regexp.Regexp.Expand, returning the new tree and the edits made.
+//
+// Options returns the options of the tree, with their defaults filled in.
+//
+// InvalidRanges returns the rune indexes of each run of invalid bytes kept by the Lossless option, as pairs of
+// start and end indexes.
+//
+// LineEnding returns the style of the line breaks in the tree, LineEndingMixed if it has more than one style, or
+// LineEndingNone if it has no line breaks.
+//
+// LineEndings returns the number of line breaks of each style, and the lines whose line breaks differ from the most
+// common style.
+//
+// NormalizeLineEndings converts every line break to the given style.
 type Rope interface {
 	String() string
 	Length() int
@@ -52,50 +136,251 @@ type Rope interface {
 	Balance() Rope
 	Depth() int
 	Data() []rune
+	Insert(int, Rope) Rope
+	InsertString(int, string) Rope
+	Delete(int, int) Rope
+	ByteLength() int
+	RuneToByte(int) int
+	ByteToRune(int) int
+	SplitAtByte(int) (Rope, Rope)
+	OffsetOfLine(int) int
+	LineColumn(int) (int, int)
+	Offset(int, int) int
+	UTF16Length() int
+	RuneToUTF16(int) int
+	UTF16ToRune(int) int
+	WriteTo(io.Writer) (int64, error)
+	IterAt(int) *Iterator
+	Runes() iter.Seq2[int, rune]
+	RunesFrom(int) iter.Seq2[int, rune]
+	Lines(int, int) *LineIterator
+	Chunks() iter.Seq[string]
+	ChunksInRange(int, int) iter.Seq[string]
+	IndexString(string) int
+	IndexFrom(string, int) int
+	LastIndexString(string) int
+	Count(string) int
+	FindRegexp(*regexp.Regexp, int) []int
+	FindAllRegexp(*regexp.Regexp, int) [][]int
+	Replace(string, string, int) (Rope, []Edit)
+	ReplaceAll(string, string) (Rope, []Edit)
+	ReplaceRegexp(*regexp.Regexp, string) (Rope, []Edit)
+	Options() Options
+	InvalidRanges() [][]int
+	LineEnding() LineEnding
+	LineEndings() LineEndingReport
+	NormalizeLineEndings(LineEnding) Rope
 
+	options() *Options
+	breaks() lineBreaks
 	leaves() []Rope
+	childCount() int
+	child(int) Rope
 }
 
-// FromFile reads a file into a rope.
+// FromFile reads a file into a rope. Each invalid byte is replaced with utf8.RuneError, unless the file is read with
+// the Lossless option of FromFileWithOptions.
 func FromFile(path string) (Rope, error) {
+	return fromFile(path, defaultOptions)
+}
+
+func fromFile(path string, o *Options) (Rope, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer func() { _ = f.Close() }()
-	r, err := FromReader(f)
+	r, err := fromReader(f, o)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 	return r, nil
 }
 
-// FromReader reads a reader into a rope.
+// FromReader reads a reader into a rope. Each invalid byte is replaced with utf8.RuneError, unless the reader is read
+// with the Lossless option of FromReaderWithOptions.
+// The input is streamed in chunks and cut into leaves on rune boundaries, which are assembled into a balanced tree.
 func FromReader(r io.Reader) (Rope, error) {
+	return fromReader(r, defaultOptions)
+}
+
+func fromReader(r io.Reader, o *Options) (Rope, error) {
 	if r == nil {
 		return nil, fmt.Errorf("reader is nil")
 	}
-	// pessimistically allocate enough room for 1:1 ratio of byte:rune
-	data := make([]rune, 0, bufio.NewReader(r).Size())
+	b := builder{opts: o}
+	buf := make([]byte, readSize)
+	var pending int
 	for {
-		s, err := bufio.NewReader(r).ReadString(0)
-		data = append(data, []rune(s)...)
+		n, err := r.Read(buf[pending:])
+		n += pending
+		var i int
+		for i < n {
+			// wait for the rest of an incomplete rune, unless there is no more input to come
+			if err == nil && !utf8.FullRune(buf[i:n]) {
+				break
+			}
+			c, size := utf8.DecodeRune(buf[i:n])
+			if c == utf8.RuneError && size == 1 {
+				b.addInvalid(buf[i])
+			} else {
+				b.add(c)
+			}
+			i += size
+		}
+		pending = copy(buf, buf[i:n])
 		if err != nil {
-			if err == io.EOF {
+			if errors.Is(err, io.EOF) {
 				break
 			}
 			return nil, fmt.Errorf("error reading: %w", err)
 		}
 	}
-	return newLeaf(data), nil
+	return b.rope(), nil
 }
 
 // FromString creates a rope from a string.
 func FromString(s string) Rope {
-	return newLeaf([]rune(s))
+	if !utf8.ValidString(s) {
+		// replace each invalid byte with utf8.RuneError, as ranging over the string does
+		s = string([]rune(s))
+	}
+	return defaultOptions.newLeaf(s)
 }
 
 // FromRune creates a rope from a single rune
 func FromRune(r rune) Rope {
-	return newLeaf([]rune{r})
+	return defaultOptions.newLeaf(string(r))
+}
+
+// offset finds the index of the given line and column in a tree. Columns beyond the end of the line are clamped
+// to the end of the line, and -1 is returned if the line does not exist.
+func offset(r Rope, line, col int) int {
+	start := r.OffsetOfLine(line)
+	if start < 0 {
+		return -1
+	}
+	if col < 0 {
+		col = 0
+	}
+	end := r.OffsetOfLine(line + 1)
+	if end < 0 {
+		end = r.Length()
+	} else {
+		// exclude the line break
+		end = lineEnd(r, start, end)
+	}
+	if start+col > end {
+		return end
+	}
+	return start + col
+}
+
+// builder accumulates runes into leaves of at most MaxLeafSize runes.
+type builder struct {
+	opts   *Options
+	leaves []Rope
+	data   []byte // the UTF-8 encoding of the runes of the next leaf
+	length int    // the number of runes in data
+}
+
+func (b *builder) add(r rune) {
+	b.data = utf8.AppendRune(b.data, r)
+	b.added()
+}
+
+// addInvalid adds an invalid byte as a rune of its own, which is kept verbatim if the options are lossless and
+// replaced with utf8.RuneError otherwise.
+func (b *builder) addInvalid(c byte) {
+	if !b.opts.Lossless {
+		b.add(utf8.RuneError)
+		return
+	}
+	b.data = append(b.data, c)
+	b.added()
+}
+
+func (b *builder) added() {
+	b.length++
+	if b.length == b.opts.MaxLeafSize {
+		b.flush()
+	}
+}
+
+// addText adds the runes of a text, starting a new leaf if the text cannot follow the current one.
+func (b *builder) addText(s string) {
+	if !joinable(b.data, s) {
+		b.flush()
+	}
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			b.addInvalid(s[i])
+		} else {
+			b.add(c)
+		}
+		i += size
+	}
+}
+
+// WriteString adds the runes of a text, so that text can be written to a builder.
+func (b *builder) WriteString(s string) (int, error) {
+	b.addText(s)
+	return len(s), nil
+}
+
+func (b *builder) flush() {
+	if b.length == 0 {
+		return
+	}
+	// the buffer is reused, as converting it to a string copies it
+	b.leaves = append(b.leaves, b.opts.newLeaf(string(b.data)))
+	b.data = b.data[:0]
+	b.length = 0
+}
+
+// rope assembles the accumulated leaves into a balanced tree.
+func (b *builder) rope() Rope {
+	b.flush()
+	return b.opts.build(b.leaves)
+}
+
+// leafText concatenates the text of the leaves of a tree into a single allocation.
+func leafText(r Rope) string {
+	var sb strings.Builder
+	sb.Grow(r.ByteLength())
+	writeLeafText(&sb, r)
+	return sb.String()
+}
+
+func writeLeafText(sb *strings.Builder, r Rope) {
+	count := r.childCount()
+	if count == 0 {
+		sb.WriteString(leafString(r))
+		return
+	}
+	for i := 0; i < count; i++ {
+		writeLeafText(sb, r.child(i))
+	}
+}
+
+// writeTo streams the leaves of a tree to a writer.
+func writeTo(r Rope, w io.Writer) (int64, error) {
+	count := r.childCount()
+	if count == 0 {
+		n, err := io.WriteString(w, leafString(r))
+		if err != nil {
+			return int64(n), fmt.Errorf("error writing: %w", err)
+		}
+		return int64(n), nil
+	}
+	var total int64
+	for i := 0; i < count; i++ {
+		n, err := writeTo(r.child(i), w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
 }
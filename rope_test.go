@@ -1,9 +1,14 @@
 package rope
 
 import (
+	"errors"
 	"io"
+	"math/bits"
+	"math/rand"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,6 +58,21 @@ func Test_FromReader(t *testing.T) {
 			reader: strings.NewReader("hello\nworld!"),
 			want:   "hello\nworld!",
 		},
+		{
+			name:   "multi-byte split across reads",
+			reader: iotest.OneByteReader(strings.NewReader("héllo €😀")),
+			want:   "héllo €😀",
+		},
+		{
+			name:   "truncated rune",
+			reader: strings.NewReader("abc\xe2\x82"),
+			want:   "abc\ufffd\ufffd",
+		},
+		{
+			name:    "read error",
+			reader:  iotest.ErrReader(errors.New("oops")),
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -67,6 +87,43 @@ func Test_FromReader(t *testing.T) {
 	}
 }
 
+func Test_FromReader_Large(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		reader func(io.Reader) io.Reader
+	}{
+		{
+			name:   "ascii",
+			input:  strings.Repeat("hello world!\n", 400_000),
+			reader: func(r io.Reader) io.Reader { return r },
+		},
+		{
+			name:   "multi-byte",
+			input:  strings.Repeat("héllo wörld €😀\n", 200_000),
+			reader: func(r io.Reader) io.Reader { return r },
+		},
+		{
+			name:   "multi-byte split across reads",
+			input:  strings.Repeat("héllo wörld €😀\n", 200_000),
+			reader: iotest.HalfReader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := FromReader(tt.reader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, r.String())
+			assert.Equal(t, strings.Count(tt.input, "\n"), r.NewLineCount())
+			leaves := r.leaves()
+			for _, leaf := range leaves {
+				require.LessOrEqual(t, leaf.Length(), maxLeafSize)
+			}
+			assert.LessOrEqual(t, r.Depth(), bits.Len(uint(len(leaves)))+1)
+		})
+	}
+}
+
 func Test_FromString(t *testing.T) {
 	tests := []struct {
 		name string
@@ -180,3 +237,58 @@ func Test_Rope_Split(t *testing.T) {
 		})
 	}
 }
+
+// benchmarkTexts are 16 MB texts of ASCII and of mixed width runes.
+var benchmarkTexts = []struct {
+	name string
+	line string
+}{
+	{name: "ascii", line: "the quick brown fox jumps over the lazy dog\n"},
+	{name: "mixed", line: "a line of € signs, emoji 😀 and ascii\n"},
+}
+
+// BenchmarkFromString_Memory reports the heap retained by a rope per byte of its UTF-8 text.
+func BenchmarkFromString_Memory(b *testing.B) {
+	for _, tt := range benchmarkTexts {
+		b.Run(tt.name, func(b *testing.B) {
+			text := strings.Repeat(tt.line, 16<<20/len(tt.line))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				r := FromStringWithOptions(text, Options{})
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(len(text)), "heap-bytes/byte")
+				runtime.KeepAlive(r)
+			}
+		})
+	}
+}
+
+func BenchmarkRope_String(b *testing.B) {
+	for _, tt := range benchmarkTexts {
+		b.Run(tt.name, func(b *testing.B) {
+			r := FromStringWithOptions(strings.Repeat(tt.line, 16<<20/len(tt.line)), Options{})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = r.String()
+			}
+		})
+	}
+}
+
+func BenchmarkRope_At(b *testing.B) {
+	for _, tt := range benchmarkTexts {
+		b.Run(tt.name, func(b *testing.B) {
+			r := FromStringWithOptions(strings.Repeat(tt.line, 16<<20/len(tt.line)), Options{})
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = r.At(rng.Intn(r.Length()))
+			}
+		})
+	}
+}
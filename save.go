@@ -0,0 +1,135 @@
+package rope
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// bom is the byte order mark.
+const bom = '\ufeff'
+
+// modeBits are the bits of a file mode which SaveFile sets: the permissions, and the setuid, setgid and sticky bits.
+const modeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// SaveOptions controls how SaveFile writes a rope.
+type SaveOptions struct {
+	// LineEnding converts every line break, whether "\n", "\r\n" or "\r", to the given style. The zero value,
+	// LineEndingNone, writes line breaks as they are.
+	LineEnding LineEnding
+	// BOM writes a UTF-8 byte order mark before the text, unless the text already begins with one.
+	BOM bool
+	// Mode is the permissions of the file if it does not already exist, which defaults to 0644. The permissions of
+	// an existing file are preserved, along with its setuid, setgid and sticky bits.
+	Mode fs.FileMode
+}
+
+// SaveError is returned by SaveFile when a rope cannot be saved, in which case the file is left unchanged. Op is the
+// step which failed: "options" if the options are invalid, or "stat", "create", "write", "sync", "chmod", "close" or
+// "rename".
+type SaveError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *SaveError) Error() string {
+	return fmt.Sprintf("error saving %s: %s: %v", e.Path, e.Op, e.Err)
+}
+
+func (e *SaveError) Unwrap() error {
+	return e.Err
+}
+
+// SaveFile writes a rope to a file, replacing it atomically: the leaves are streamed to a temporary file in the same
+// directory, which is synced to disk and then renamed over the original, so that the file holds either its old or
+// its new contents even if the process or system fails. If the path is a symbolic link, the file it refers to is
+// replaced. Any error is a *SaveError.
+func SaveFile(path string, r Rope, opts SaveOptions) error {
+	if opts.LineEnding < LineEndingNone || opts.LineEnding > LineEndingCR {
+		return &SaveError{Op: "options", Path: path, Err: fmt.Errorf("invalid line ending %v", opts.LineEnding)}
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	mode := opts.Mode & modeBits
+	if mode == 0 {
+		mode = 0o644
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		mode = info.Mode() & modeBits
+	case !errors.Is(err, fs.ErrNotExist):
+		return &SaveError{Op: "stat", Path: path, Err: err}
+	}
+
+	dir, name := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return &SaveError{Op: "create", Path: path, Err: err}
+	}
+	if err := writeTemp(f, r, mode, opts); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		err.Path = path
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return &SaveError{Op: "rename", Path: path, Err: err}
+	}
+	syncDir(dir)
+	return nil
+}
+
+// writeTemp writes a rope to a temporary file, syncing and closing it, and setting its permissions.
+func writeTemp(f *os.File, r Rope, mode fs.FileMode, opts SaveOptions) *SaveError {
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	if ending := opts.LineEnding.text(); ending != "" {
+		w = &lineEndingWriter{w: bw, ending: ending}
+	}
+	if opts.BOM && (r.Length() == 0 || r.At(0) != bom) {
+		_, _ = bw.WriteRune(bom)
+	}
+	if _, err := r.WriteTo(w); err != nil {
+		return &SaveError{Op: "write", Err: err}
+	}
+	if lw, ok := w.(*lineEndingWriter); ok {
+		if err := lw.flush(); err != nil {
+			return &SaveError{Op: "write", Err: err}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return &SaveError{Op: "write", Err: err}
+	}
+	if err := f.Sync(); err != nil {
+		return &SaveError{Op: "sync", Err: err}
+	}
+	if err := f.Chmod(mode); err != nil {
+		return &SaveError{Op: "chmod", Err: err}
+	}
+	if err := f.Close(); err != nil {
+		return &SaveError{Op: "close", Err: err}
+	}
+	return nil
+}
+
+// syncDir syncs a directory, so that a file renamed into it survives a system failure. This is not supported on
+// every platform, so errors are ignored.
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,181 @@
+package rope
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requireNoTempFiles checks that a directory holds only the given files.
+func requireNoTempFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	require.ElementsMatch(t, want, got)
+}
+
+func TestSaveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "text")
+	r := FromStringWithOptions("héllo, 世界 😀\n", Options{MaxLeafSize: 2})
+
+	require.NoError(t, SaveFile(path, r, SaveOptions{}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, r.String(), string(data))
+	requireNoTempFiles(t, dir, "text")
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+		// the permissions of an existing file are preserved
+		require.NoError(t, os.Chmod(path, 0o600))
+		require.NoError(t, SaveFile(path, r.InsertString(0, "x"), SaveOptions{Mode: 0o666}))
+		info, err = os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		// as are its setuid, setgid and sticky bits
+		require.NoError(t, os.Chmod(path, 0o755|os.ModeSetuid|os.ModeSticky))
+		require.NoError(t, SaveFile(path, r, SaveOptions{}))
+		info, err = os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, 0o755|os.ModeSetuid|os.ModeSticky, info.Mode()&modeBits)
+
+		require.NoError(t, SaveFile(filepath.Join(dir, "new"), r, SaveOptions{Mode: 0o640}))
+		info, err = os.Stat(filepath.Join(dir, "new"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	}
+}
+
+func TestSaveFile_LineEndings(t *testing.T) {
+	input := "a\nb\r\nc\rd\r\n\r\n\n\re\r"
+	tests := []struct {
+		ending LineEnding
+		want   string
+	}{
+		{ending: LineEndingNone, want: input},
+		{ending: LineEndingLF, want: "a\nb\nc\nd\n\n\n\ne\n"},
+		{ending: LineEndingCRLF, want: "a\r\nb\r\nc\r\nd\r\n\r\n\r\n\r\ne\r\n"},
+		{ending: LineEndingCR, want: "a\rb\rc\rd\r\r\r\re\r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ending.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "text")
+			// single-rune leaves split each "\r\n" across leaves
+			for _, size := range []int{1, 256} {
+				r := FromStringWithOptions(input, Options{MaxLeafSize: size})
+				require.NoError(t, SaveFile(path, r, SaveOptions{LineEnding: tt.ending}))
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, string(data), "leaf size %d", size)
+			}
+		})
+	}
+}
+
+func TestSaveFile_BOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+
+	require.NoError(t, SaveFile(path, FromString("abc\n"), SaveOptions{BOM: true, LineEnding: LineEndingCRLF}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbfabc\r\n", string(data))
+
+	// a byte order mark is not written twice
+	require.NoError(t, SaveFile(path, FromString("\ufeffabc"), SaveOptions{BOM: true}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbfabc", string(data))
+
+	require.NoError(t, SaveFile(path, FromString(""), SaveOptions{BOM: true}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbf", string(data))
+}
+
+func TestSaveFile_Lossless(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+	input := "caf\xe9\r\n\xff"
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	r, err := FromFileWithOptions(path, Options{Lossless: true})
+	require.NoError(t, err)
+	require.NoError(t, SaveFile(path, r, SaveOptions{}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(data))
+}
+
+func TestSaveFile_Mapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "text")
+	input := strings.Repeat("0123456789abcdef\n", 10000)
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	// a mapped rope can be saved over the file it was mapped from
+	r, err := FromFileMapped(path)
+	require.NoError(t, err)
+	r = r.Delete(0, 17)
+	r = r.InsertString(r.Length()-17, "end\n")
+	require.NoError(t, SaveFile(path, r, SaveOptions{}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, input[17:len(input)-17]+"end\n"+input[len(input)-17:], string(data))
+	assert.Equal(t, string(data), r.String())
+}
+
+func TestSaveFile_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.WriteFile(target, []byte("old"), 0o600))
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+
+	require.NoError(t, SaveFile(link, FromString("new"), SaveOptions{}))
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+}
+
+func TestSaveFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	err := SaveFile(filepath.Join(dir, "missing", "text"), FromString("abc"), SaveOptions{})
+	var saveErr *SaveError
+	require.ErrorAs(t, err, &saveErr)
+	assert.Equal(t, "create", saveErr.Op)
+	assert.Equal(t, filepath.Join(dir, "missing", "text"), saveErr.Path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// a directory cannot be replaced, and the temporary file is removed
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dir", "file"), nil, 0o600))
+	err = SaveFile(filepath.Join(dir, "dir"), FromString("abc"), SaveOptions{})
+	require.ErrorAs(t, err, &saveErr)
+	assert.Equal(t, "rename", saveErr.Op)
+	requireNoTempFiles(t, dir, "dir")
+
+	err = SaveFile(filepath.Join(dir, "text"), FromString("abc"), SaveOptions{LineEnding: LineEnding(10)})
+	require.ErrorAs(t, err, &saveErr)
+	assert.Equal(t, "options", saveErr.Op)
+	requireNoTempFiles(t, dir, "dir")
+	assert.False(t, errors.Is(err, os.ErrNotExist))
+}
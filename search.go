@@ -0,0 +1,138 @@
+package rope
+
+import "slices"
+
+// matcher finds occurrences of a pattern in a stream of runes using the Knuth-Morris-Pratt algorithm,
+// so that a search never has to step backwards across leaf boundaries.
+type matcher struct {
+	pattern []rune
+	table   []int // length of the longest proper prefix of pattern[:i+1] which is also a suffix
+	matched int
+}
+
+func newMatcher(pattern []rune) *matcher {
+	table := make([]int, len(pattern))
+	var k int
+	for i := 1; i < len(pattern); i++ {
+		for k > 0 && pattern[i] != pattern[k] {
+			k = table[k-1]
+		}
+		if pattern[i] == pattern[k] {
+			k++
+		}
+		table[i] = k
+	}
+	return &matcher{
+		pattern: pattern,
+		table:   table,
+	}
+}
+
+// next feeds a rune to the matcher, returning true if it completes an occurrence of the pattern.
+// The matcher is reset after each occurrence, so occurrences never overlap.
+func (m *matcher) next(r rune) bool {
+	for m.matched > 0 && m.pattern[m.matched] != r {
+		m.matched = m.table[m.matched-1]
+	}
+	if m.pattern[m.matched] == r {
+		m.matched++
+	}
+	if m.matched == len(m.pattern) {
+		m.matched = 0
+		return true
+	}
+	return false
+}
+
+// indexFrom returns the index of the first occurrence of s in a tree at or after from, or -1 if there is none.
+func indexFrom(r Rope, s string, from int) int {
+	if from < 0 {
+		from = 0
+	}
+	if from > r.Length() {
+		return -1
+	}
+	pattern := []rune(s)
+	if len(pattern) == 0 {
+		return from
+	}
+	m := newMatcher(pattern)
+	offset := from
+	for text := range textInRange(r, from, r.Length()) {
+		for _, c := range text {
+			offset++
+			if m.next(c) {
+				return offset - len(pattern)
+			}
+		}
+	}
+	return -1
+}
+
+// indexAll returns the indexes of up to n non-overlapping occurrences of s in a tree, in a single pass.
+// If n is negative, all occurrences are returned. As with strings.Replace, an empty string occurs once
+// before and after each rune.
+func indexAll(r Rope, s string, n int) []int {
+	var indexes []int
+	pattern := []rune(s)
+	if len(pattern) == 0 {
+		for i := 0; i <= r.Length() && (n < 0 || len(indexes) < n); i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+	m := newMatcher(pattern)
+	var offset int
+	for text := range textInRange(r, 0, r.Length()) {
+		for _, c := range text {
+			offset++
+			if m.next(c) {
+				if n >= 0 && len(indexes) == n {
+					return indexes
+				}
+				indexes = append(indexes, offset-len(pattern))
+			}
+		}
+	}
+	return indexes
+}
+
+// lastIndex returns the index of the last occurrence of s in a tree, or -1 if there is none.
+// The tree is walked backwards, matching against the reversed pattern.
+func lastIndex(r Rope, s string) int {
+	pattern := []rune(s)
+	if len(pattern) == 0 {
+		return r.Length()
+	}
+	slices.Reverse(pattern)
+	m := newMatcher(pattern)
+	it := r.IterAt(r.Length())
+	for {
+		c, ok := it.Prev()
+		if !ok {
+			return -1
+		}
+		if m.next(c) {
+			return it.Offset()
+		}
+	}
+}
+
+// count returns the number of non-overlapping occurrences of s in a tree.
+// As with strings.Count, an empty string occurs once before and after each rune.
+func count(r Rope, s string) int {
+	pattern := []rune(s)
+	if len(pattern) == 0 {
+		return r.Length() + 1
+	}
+	m := newMatcher(pattern)
+	var n int
+	for text := range textInRange(r, 0, r.Length()) {
+		for _, c := range text {
+			if m.next(c) {
+				n++
+			}
+		}
+	}
+	return n
+}
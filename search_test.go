@@ -0,0 +1,258 @@
+package rope
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSearchRope splits the string into a tree with a leaf boundary between every rune.
+func testSearchRope(s string) Rope {
+	var leaves []Rope
+	for _, r := range s {
+		leaves = append(leaves, FromRune(r))
+	}
+	if len(leaves) == 0 {
+		return FromString("")
+	}
+	return defaultOptions.merge(leaves, 0, len(leaves))
+}
+
+func TestRope_IndexString(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		s    string
+		want int
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			s:    "a",
+			want: -1,
+		},
+		{
+			name: "empty substring",
+			rope: FromString("abc"),
+			s:    "",
+			want: 0,
+		},
+		{
+			name: "leaf",
+			rope: FromString("abcabc"),
+			s:    "ca",
+			want: 2,
+		},
+		{
+			name: "multi-byte",
+			rope: FromString("é€😀é€"),
+			s:    "😀é",
+			want: 2,
+		},
+		{
+			name: "across leaves",
+			rope: testSearchRope("hello wörld, hello world"),
+			s:    "o world",
+			want: 17,
+		},
+		{
+			name: "partial match before match",
+			rope: testSearchRope("aabaabaaab"),
+			s:    "aaab",
+			want: 6,
+		},
+		{
+			name: "not found",
+			rope: testSearchRope("hello world"),
+			s:    "worlds",
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.rope.IndexString(tt.s), "IndexString(%q)", tt.s)
+		})
+	}
+}
+
+func TestRope_IndexFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		s    string
+		from int
+		want int
+	}{
+		{
+			name: "from start",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "ab",
+			from: 0,
+			want: 2,
+		},
+		{
+			name: "from inside match",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "ab",
+			from: 3,
+			want: 5,
+		},
+		{
+			name: "from match",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "ab",
+			from: 5,
+			want: 5,
+		},
+		{
+			name: "from before start",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "ab",
+			from: -1,
+			want: 2,
+		},
+		{
+			name: "from after end",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "ab",
+			from: 10,
+			want: -1,
+		},
+		{
+			name: "empty substring",
+			rope: testSearchRope("é€ab€ab"),
+			s:    "",
+			from: 7,
+			want: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.rope.IndexFrom(tt.s, tt.from), "IndexFrom(%q, %v)", tt.s, tt.from)
+		})
+	}
+}
+
+func TestRope_LastIndexString(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		s    string
+		want int
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			s:    "a",
+			want: -1,
+		},
+		{
+			name: "empty substring",
+			rope: FromString("abc"),
+			s:    "",
+			want: 3,
+		},
+		{
+			name: "leaf",
+			rope: FromString("abcabc"),
+			s:    "ab",
+			want: 3,
+		},
+		{
+			name: "multi-byte",
+			rope: FromString("é€😀é€😀"),
+			s:    "€😀",
+			want: 4,
+		},
+		{
+			name: "across leaves",
+			rope: testSearchRope("hello wörld, hello world"),
+			s:    "o w",
+			want: 17,
+		},
+		{
+			name: "overlapping",
+			rope: testSearchRope("aaaa"),
+			s:    "aa",
+			want: 2,
+		},
+		{
+			name: "not found",
+			rope: testSearchRope("hello world"),
+			s:    "hello!",
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.rope.LastIndexString(tt.s), "LastIndexString(%q)", tt.s)
+		})
+	}
+}
+
+func TestRope_Count(t *testing.T) {
+	tests := []struct {
+		name string
+		rope Rope
+		s    string
+		want int
+	}{
+		{
+			name: "empty",
+			rope: FromString(""),
+			s:    "a",
+			want: 0,
+		},
+		{
+			name: "empty substring",
+			rope: FromString("é€😀"),
+			s:    "",
+			want: 4,
+		},
+		{
+			name: "across leaves",
+			rope: testSearchRope("hello wörld, hello world"),
+			s:    "llo",
+			want: 2,
+		},
+		{
+			name: "non-overlapping",
+			rope: testSearchRope("aaaaa"),
+			s:    "aa",
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.rope.Count(tt.s), "Count(%q)", tt.s)
+		})
+	}
+}
+
+func TestRope_Search_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []rune("ab€")
+	random := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			sb.WriteRune(alphabet[rng.Intn(len(alphabet))])
+		}
+		return sb.String()
+	}
+	runeIndex := func(s string, i int) int {
+		if i < 0 {
+			return i
+		}
+		return utf8.RuneCountInString(s[:i])
+	}
+	for i := 0; i < 500; i++ {
+		s, sub := random(rng.Intn(40)), random(1+rng.Intn(4))
+		r := testSearchRope(s)
+		assert.Equalf(t, runeIndex(s, strings.Index(s, sub)), r.IndexString(sub), "IndexString(%q) in %q", sub, s)
+		assert.Equalf(t, runeIndex(s, strings.LastIndex(s, sub)), r.LastIndexString(sub), "LastIndexString(%q) in %q", sub, s)
+		assert.Equalf(t, strings.Count(s, sub), r.Count(sub), "Count(%q) in %q", sub, s)
+	}
+}